@@ -0,0 +1,275 @@
+// Command isy99 runs the ISY publisher and inspects or controls the ISY from the command line
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/iotdomain/isy99/internal"
+)
+
+const usage = `Usage: isy99 [options] <command> [arguments]
+
+Commands:
+  run                      run the publisher, configured with isy99.yaml
+  nodes                    list the nodes with their type, folder and value
+  status                   list the status of the nodes
+  set <node> <value>       turn a node on or off, or set its level 0-100
+  scene                    list the scenes
+  scene <scene> <on|off>   turn a scene on or off
+  program list             list the programs
+  program <cmd> <id>       send a command to a program: run, runThen, runElse, stop, enable, disable
+  vars                     list the integer and state variables
+  dump <folder>            save the gateway REST responses as simulation files in folder
+
+Options:
+`
+
+// dumpPaths are the REST paths saved by the dump command
+var dumpPaths = []string{
+	"/rest/config",
+	"/rest/nodes",
+	"/rest/status",
+	"/rest/programs",
+	"/rest/vars/get/" + internal.VarTypeInteger,
+	"/rest/vars/get/" + internal.VarTypeState,
+	"/rest/vars/definitions/" + internal.VarTypeInteger,
+	"/rest/vars/definitions/" + internal.VarTypeState,
+}
+
+func main() {
+	gatewayAddress := flag.String("gateway", os.Getenv("ISY_GATEWAY"), "ISY gateway address. Discovered if empty")
+	login := flag.String("login", os.Getenv("ISY_LOGIN"), "ISY gateway login name")
+	password := flag.String("password", os.Getenv("ISY_PASSWORD"), "ISY gateway password")
+	captureFolder := flag.String("capture", "", "capture the gateway responses as simulation files in this folder")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if args[0] == "run" {
+		internal.Run()
+		return
+	}
+	address := *gatewayAddress
+	if address == "" {
+		discovered, err := internal.DiscoverIsy(internal.SSDPAddress, internal.DefaultDiscoveryTimeout)
+		if err != nil {
+			exitOnError(fmt.Errorf("%s. Use -gateway to set the gateway address", err))
+		}
+		address = discovered
+	}
+	isyAPI := internal.NewIsyAPI(address, *login, *password)
+	isyAPI.SetCaptureFolder(*captureFolder)
+
+	var err error
+	switch args[0] {
+	case "nodes":
+		err = listNodes(isyAPI)
+	case "status":
+		err = listStatus(isyAPI)
+	case "set":
+		if len(args) != 3 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = setNode(isyAPI, args[1], args[2])
+	case "scene":
+		if len(args) == 1 {
+			err = listScenes(isyAPI)
+		} else if len(args) == 3 {
+			err = setScene(isyAPI, args[1], args[2])
+		} else {
+			flag.Usage()
+			os.Exit(2)
+		}
+	case "program":
+		if len(args) == 2 && args[1] == "list" {
+			err = listPrograms(isyAPI)
+		} else if len(args) == 3 && internal.IsProgramCommand(args[1]) {
+			err = isyAPI.WriteProgramCommand(args[2], args[1])
+		} else {
+			flag.Usage()
+			os.Exit(2)
+		}
+	case "vars":
+		err = listVariables(isyAPI)
+	case "dump":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		err = dump(isyAPI, args[1])
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	exitOnError(err)
+}
+
+// exitOnError prints the error and exits if err is not nil
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "isy99: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// listNodes prints the nodes with their type, folder and value
+func listNodes(isyAPI *internal.IsyAPI) error {
+	isyNodes, err := isyAPI.ReadIsyNodes()
+	if err != nil {
+		return err
+	}
+	folders := make(map[string]string)
+	for _, folder := range isyNodes.Folders {
+		folders[folder.Address] = folder.Name
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ADDRESS\tNAME\tTYPE\tFOLDER\tVALUE")
+	for _, isyNode := range isyNodes.Nodes {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", isyNode.Address, isyNode.Name, isyNode.Type,
+			folders[isyNode.Parent], isyNode.Property.Formatted)
+	}
+	return writer.Flush()
+}
+
+// listStatus prints the status of the nodes
+func listStatus(isyAPI *internal.IsyAPI) error {
+	isyStatus, err := isyAPI.ReadIsyStatus()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ADDRESS\tPROPERTY\tVALUE\tFORMATTED\tUOM")
+	for _, node := range isyStatus.Nodes {
+		for _, prop := range node.Props {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", node.Address, prop.ID, prop.Value, prop.Formatted, prop.UOM)
+		}
+	}
+	return writer.Flush()
+}
+
+// setNode turns a node on or off, or sets its level as a percentage
+func setNode(isyAPI *internal.IsyAPI, address string, value string) error {
+	switch strings.ToLower(value) {
+	case "on", "true":
+		return isyAPI.WriteOnOff(address, true)
+	case "off", "false":
+		return isyAPI.WriteOnOff(address, false)
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+	if err != nil || percent < 0 || percent > 100 {
+		return fmt.Errorf("invalid value '%s'. Use on, off or a level 0-100", value)
+	} else if percent == 0 {
+		return isyAPI.WriteOnOff(address, false)
+	}
+	return isyAPI.WriteLevel(address, (percent*255+50)/100)
+}
+
+// listScenes prints the scenes with their members
+func listScenes(isyAPI *internal.IsyAPI) error {
+	isyNodes, err := isyAPI.ReadIsyNodes()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ADDRESS\tNAME\tMEMBERS")
+	for _, group := range isyNodes.Groups {
+		if group.IsScene() {
+			fmt.Fprintf(writer, "%s\t%s\t%s\n", group.Address, group.Name, strings.Join(group.Members, ", "))
+		}
+	}
+	return writer.Flush()
+}
+
+// setScene turns a scene on or off. The scene can be given by address or name.
+func setScene(isyAPI *internal.IsyAPI, scene string, value string) error {
+	isyNodes, err := isyAPI.ReadIsyNodes()
+	if err != nil {
+		return err
+	}
+	for _, group := range isyNodes.Groups {
+		if group.IsScene() && (group.Address == scene || strings.EqualFold(group.Name, scene)) {
+			onOff := strings.ToLower(value) == "on" || strings.ToLower(value) == "true"
+			return isyAPI.WriteOnOff(group.Address, onOff)
+		}
+	}
+	return fmt.Errorf("scene '%s' not found", scene)
+}
+
+// listPrograms prints the programs with their status
+func listPrograms(isyAPI *internal.IsyAPI) error {
+	programs, err := isyAPI.ReadPrograms()
+	if err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "ID\tNAME\tENABLED\tSTATUS\tRUNNING\tLAST RUN")
+	for _, program := range programs.Programs {
+		if program.Folder {
+			continue
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%v\t%v\t%s\t%s\n", program.ID, program.Name, program.Enabled,
+			program.Status, program.Running, program.LastRunTime)
+	}
+	return writer.Flush()
+}
+
+// listVariables prints the integer and state variables with their names
+func listVariables(isyAPI *internal.IsyAPI) error {
+	writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "TYPE\tID\tNAME\tVALUE\tINIT\tTIMESTAMP")
+	for _, varType := range []string{internal.VarTypeInteger, internal.VarTypeState} {
+		typeName := "integer"
+		if varType == internal.VarTypeState {
+			typeName = "state"
+		}
+		definitions, err := isyAPI.ReadVariableDefinitions(varType)
+		if err != nil {
+			return err
+		}
+		names := definitions.Names()
+		variables, err := isyAPI.ReadVariables(varType)
+		if err != nil {
+			return err
+		}
+		for _, variable := range variables.Variables {
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\n", typeName, variable.ID, names[variable.ID],
+				variable.Value, variable.Init, variable.Timestamp)
+		}
+	}
+	return writer.Flush()
+}
+
+// dump saves the gateway REST responses in the folder using the simulation file layout
+// The folder can be used as the gateway address file://<folder> to simulate the gateway.
+func dump(isyAPI *internal.IsyAPI, folder string) error {
+	for _, restPath := range dumpPaths {
+		response, err := isyAPI.ReadRaw(restPath)
+		if err != nil {
+			return err
+		}
+		filename := internal.SimulationFilename(folder, restPath)
+		err = os.MkdirAll(path.Dir(filename), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(filename, response, 0644)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Println(filename)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Package internal for the climate node with the weather and irrigation data of the ISY
+package internal
+
+import (
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Climate outputs that are not defined by iotdomain
+const (
+	// OutputTypeEvapotranspiration with the evapotranspiration (ETo) of today
+	OutputTypeEvapotranspiration types.OutputType = "evapotranspiration"
+	// OutputTypeIrrigationRequirement with the accumulated irrigation requirement
+	OutputTypeIrrigationRequirement types.OutputType = "irrigationRequirement"
+	// OutputTypeWaterDeficit with the water deficit of yesterday
+	OutputTypeWaterDeficit types.OutputType = "waterDeficit"
+	// OutputTypeRain with the total rain of today
+	OutputTypeRain types.OutputType = "rain"
+)
+
+// ClimateNodeHWID is the hardware ID of the node with the climate data, in the namespace of the gateway
+const ClimateNodeHWID = "climate"
+
+// Output instances of the daily high and low temperature
+const (
+	ClimateInstanceHigh = "high"
+	ClimateInstanceLow  = "low"
+)
+
+// climateOutput with the output type and instance of a climate value
+type climateOutput struct {
+	outputType types.OutputType
+	instance   string
+	value      string
+}
+
+// climateOutputs returns the outputs of the climate data
+func climateOutputs(isyClimate *IsyClimate) []climateOutput {
+	return []climateOutput{
+		{types.OutputTypeTemperature, types.DefaultOutputInstance, isyClimate.Temperature},
+		{types.OutputTypeTemperature, ClimateInstanceHigh, isyClimate.TemperatureHigh},
+		{types.OutputTypeTemperature, ClimateInstanceLow, isyClimate.TemperatureLow},
+		{types.OutputTypeHumidity, types.DefaultOutputInstance, isyClimate.Humidity},
+		{types.OutputTypeAtmosphericPressure, types.DefaultOutputInstance, isyClimate.Pressure},
+		{types.OutputTypeDewpoint, types.DefaultOutputInstance, isyClimate.DewPoint},
+		{types.OutputTypeWindSpeed, types.DefaultOutputInstance, isyClimate.WindSpeed},
+		{types.OutputTypeWindHeading, types.DefaultOutputInstance, isyClimate.WindDirection},
+		{OutputTypeRain, types.DefaultOutputInstance, isyClimate.TotalRainToday},
+		{OutputTypeEvapotranspiration, types.DefaultOutputInstance, isyClimate.Evapotranspiration},
+		{OutputTypeIrrigationRequirement, types.DefaultOutputInstance, isyClimate.IrrigationRequirement},
+		{OutputTypeWaterDeficit, types.DefaultOutputInstance, isyClimate.WaterDeficitYesterday},
+	}
+}
+
+// UpdateClimate reads the climate module data and publishes it on the climate node
+// Values that the module does not report are not published.
+func (app *IsyApp) UpdateClimate() {
+	pub := app.pub
+	isyClimate, err := app.isyAPI.ReadIsyClimate()
+	if err != nil {
+		logrus.Warningf("UpdateClimate: Error reading climate data: %s", err)
+		return
+	}
+	climateHWID := app.nodeHWID(ClimateNodeHWID)
+	if pub.GetNodeByHWID(climateHWID) == nil {
+		pub.CreateNode(climateHWID, types.NodeTypeWeatherService)
+		pub.UpdateNodeConfig(climateHWID, types.NodeAttrName, &types.ConfigAttr{
+			DataType:    types.DataTypeString,
+			Description: "Name of the ISY climate node",
+			Default:     "ISY Climate",
+		})
+		pub.UpdateNodeStatus(climateHWID, map[types.NodeStatus]string{
+			types.NodeStatusRunState: types.NodeRunStateReady,
+		})
+	}
+	for _, climate := range climateOutputs(isyClimate) {
+		value, unit := ParseClimateValue(climate.value)
+		if value == "" {
+			continue
+		}
+		if pub.GetOutputByNodeHWID(climateHWID, climate.outputType, climate.instance) == nil {
+			output := pub.CreateOutput(climateHWID, climate.outputType, climate.instance)
+			output.Unit = unit
+			pub.UpdateOutput(output)
+		}
+		pub.UpdateOutputValue(climateHWID, climate.outputType, climate.instance, value)
+	}
+}
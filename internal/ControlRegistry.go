@@ -0,0 +1,126 @@
+// Package internal with the registry of control definitions read from the gateway
+package internal
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/iotdomain/iotdomain-go/types"
+)
+
+// knownControlOutputs maps ISY controls to iotdomain output types
+// Controls that are not listed use an output type derived from the control label, so controls
+// added by newer ISY firmware are published without code changes.
+var knownControlOutputs = map[string]types.OutputType{
+	"ST":     types.OutputTypeOnOffSwitch,
+	"OL":     types.OutputTypeDimmer,
+	"CLIHUM": types.OutputTypeHumidity,
+	"TPW":    types.OutputTypeElectricEnergy,
+	"CPW":    types.OutputTypeElectricPower,
+	"CV":     types.OutputTypeVoltage,
+	"CC":     types.OutputTypeElectricCurrent,
+	"BATLVL": types.OutputTypeBattery,
+}
+
+// knownControlNodeTypes maps the ISY control of the main node property to the node type
+var knownControlNodeTypes = map[string]types.NodeType{
+	"ST":     types.NodeTypeOnOffSwitch,
+	"OL":     types.NodeTypeDimmer,
+	"CLISPH": types.NodeTypeThermostat,
+	"CLISPC": types.NodeTypeThermostat,
+	"CLIMD":  types.NodeTypeThermostat,
+	"TPW":    types.NodeTypePowerMeter,
+	"CPW":    types.NodeTypePowerMeter,
+}
+
+// ControlRegistry with the definitions of the controls supported by the gateway
+// The registry is loaded from the gateway configuration. See IsyControl.
+type ControlRegistry struct {
+	controls map[string]IsyControl
+	mutex    sync.RWMutex
+}
+
+// Load replaces the control definitions in the registry
+func (registry *ControlRegistry) Load(controls []IsyControl) {
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	registry.controls = make(map[string]IsyControl)
+	for _, control := range controls {
+		registry.controls[control.Name] = control
+	}
+}
+
+// GetControl returns the definition of the control with the given name
+// If the gateway did not define the control then a read-only control with the name as label is returned.
+func (registry *ControlRegistry) GetControl(name string) IsyControl {
+	registry.mutex.RLock()
+	defer registry.mutex.RUnlock()
+	control, found := registry.controls[name]
+	if !found {
+		control = IsyControl{Name: name, Label: name, ReadOnly: true}
+	}
+	return control
+}
+
+// HasInput returns true if an input is to be created for the control
+// Read-only status controls have an input when the gateway supports on/off commands for them.
+func (registry *ControlRegistry) HasInput(name string) bool {
+	control := registry.GetControl(name)
+	if !control.ReadOnly {
+		return true
+	}
+	if name == "ST" {
+		registry.mutex.RLock()
+		defer registry.mutex.RUnlock()
+		_, hasOn := registry.controls["DON"]
+		_, hasOff := registry.controls["DOF"]
+		return hasOn && hasOff
+	}
+	return false
+}
+
+// OutputType returns the output type used to publish the control
+func (registry *ControlRegistry) OutputType(name string) types.OutputType {
+	outputType, found := knownControlOutputs[name]
+	if found {
+		return outputType
+	}
+	control := registry.GetControl(name)
+	return types.OutputType(labelToID(control.Label, name))
+}
+
+// NodeType returns the node type of a node whose main property is the given control
+func (registry *ControlRegistry) NodeType(name string) types.NodeType {
+	nodeType, found := knownControlNodeTypes[name]
+	if !found {
+		return types.NodeTypeUnknown
+	}
+	return nodeType
+}
+
+// labelToID converts a control label to a camelCase identifier, eg "Ramp Rate" becomes "rampRate"
+// Labels without letters or digits use the control ID instead.
+func labelToID(label string, controlID string) string {
+	words := strings.FieldsFunc(label, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return controlID
+	}
+	for i, word := range words {
+		if i == 0 {
+			words[i] = strings.ToLower(word)
+		} else {
+			runes := []rune(strings.ToLower(word))
+			runes[0] = unicode.ToUpper(runes[0])
+			words[i] = string(runes)
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// NewControlRegistry creates an empty control registry
+func NewControlRegistry() *ControlRegistry {
+	return &ControlRegistry{controls: make(map[string]IsyControl)}
+}
@@ -0,0 +1,156 @@
+// Package internal for the Elk security panel areas and zones
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Elk outputs, inputs and attributes
+const (
+	// OutputTypeArmedState with the armed state of an Elk area, eg disarmed or armedAway
+	OutputTypeArmedState types.OutputType = "armedState"
+	// OutputTypeZoneStatus with the status of an Elk zone: normal, trouble, violated or bypassed
+	OutputTypeZoneStatus types.OutputType = "zoneStatus"
+	// InputTypeArm to arm an area with the arm type, eg away, stay, night or vacation
+	InputTypeArm types.InputType = "arm"
+	// InputTypeDisarm to disarm an area
+	InputTypeDisarm types.InputType = "disarm"
+	// NodeAttrElkCode with the user code used to arm and disarm an area
+	NodeAttrElkCode types.NodeAttr = "elkCode"
+	// NodeAttrElkID with the Elk ID of an ISY node
+	NodeAttrElkID types.NodeAttr = "elkID"
+)
+
+// elkEventControl is the event stream control of Elk area and zone events
+const elkEventControl = "_19"
+
+// elkAreaHWID returns the node hardware ID of an Elk area
+func elkAreaHWID(areaID string) string {
+	return "elk-area-" + areaID
+}
+
+// elkZoneHWID returns the node hardware ID of an Elk zone
+func elkZoneHWID(zoneID string) string {
+	return "elk-zone-" + zoneID
+}
+
+// stateName returns the name of a state value, or the value itself if it is not known
+func stateName(names []string, value string) string {
+	index, err := strconv.Atoi(value)
+	if err != nil || index < 0 || index >= len(names) {
+		return value
+	}
+	return names[index]
+}
+
+// UpdateElk discovers the Elk areas and zones and updates their status
+func (app *IsyApp) UpdateElk() {
+	pub := app.pub
+	topology, err := app.isyAPI.ReadElkTopology()
+	if err != nil {
+		logrus.Warningf("UpdateElk: Error reading Elk topology: %s", err)
+		return
+	}
+	for _, area := range topology.Areas {
+		areaHWID := app.nodeHWID(elkAreaHWID(area.ID))
+		if pub.GetNodeByHWID(areaHWID) == nil {
+			pub.CreateNode(areaHWID, types.NodeTypeAlarm)
+			pub.UpdateNodeConfig(areaHWID, types.NodeAttrName, &types.ConfigAttr{
+				DataType:    types.DataTypeString,
+				Description: "Name of Elk area",
+				Default:     area.Name,
+			})
+			pub.UpdateNodeConfig(areaHWID, NodeAttrElkCode, &types.ConfigAttr{
+				DataType:    types.DataTypeString,
+				Description: "Elk user code to arm and disarm the area",
+				Secret:      true,
+			})
+			pub.UpdateNodeStatus(areaHWID, map[types.NodeStatus]string{
+				types.NodeStatusRunState: types.NodeRunStateReady,
+			})
+			pub.CreateOutput(areaHWID, OutputTypeArmedState, types.DefaultOutputInstance)
+			pub.CreateInput(areaHWID, InputTypeArm, types.DefaultInputInstance, app.HandleInputCommand)
+			pub.CreateInput(areaHWID, InputTypeDisarm, types.DefaultInputInstance, app.HandleInputCommand)
+		}
+		for _, zone := range area.Zones {
+			zoneHWID := app.nodeHWID(elkZoneHWID(zone.ID))
+			if pub.GetNodeByHWID(zoneHWID) == nil {
+				pub.CreateNode(zoneHWID, types.NodeTypeSensor)
+				pub.UpdateNodeConfig(zoneHWID, types.NodeAttrName, &types.ConfigAttr{
+					DataType:    types.DataTypeString,
+					Description: "Name of Elk zone",
+					Default:     zone.Name,
+				})
+				pub.UpdateNodeStatus(zoneHWID, map[types.NodeStatus]string{
+					types.NodeStatusRunState: types.NodeRunStateReady,
+				})
+				pub.CreateOutput(zoneHWID, OutputTypeZoneStatus, types.DefaultOutputInstance)
+			}
+		}
+	}
+
+	status, err := app.isyAPI.ReadElkStatus()
+	if err != nil {
+		logrus.Warningf("UpdateElk: Error reading Elk status: %s", err)
+		return
+	}
+	app.updateElkStatus(status)
+}
+
+// updateElkStatus publishes the area armed state and zone status
+// Other area and zone event types are ignored.
+func (app *IsyApp) updateElkStatus(status *IsyElkStatus) {
+	pub := app.pub
+	for _, areaEvent := range status.AreaEvents {
+		if areaEvent.Type == ElkAreaEventArmedState {
+			pub.UpdateOutputValue(app.nodeHWID(elkAreaHWID(areaEvent.Area)), OutputTypeArmedState, types.DefaultOutputInstance,
+				stateName(ElkArmedStates, areaEvent.Value))
+		}
+	}
+	for _, zoneEvent := range status.ZoneEvents {
+		if zoneEvent.Type == ElkZoneEventLogicalStatus {
+			pub.UpdateOutputValue(app.nodeHWID(elkZoneHWID(zoneEvent.Zone)), OutputTypeZoneStatus, types.DefaultOutputInstance,
+				stateName(ElkZoneStatus, zoneEvent.Value))
+		}
+	}
+}
+
+// handleElkEvent publishes Elk area and zone changes from the event stream
+// The event info contains the same area and zone elements as the Elk status
+func (app *IsyApp) handleElkEvent(event *IsyEvent) {
+	status := IsyElkStatus{}
+	err := unmarshalEventInfo(event, &status)
+	if err != nil {
+		logrus.Warningf("handleElkEvent: Invalid Elk event info: %s", err)
+		return
+	}
+	app.updateElkStatus(&status)
+}
+
+// ArmElkArea arms or disarms an Elk area using the code from the area configuration
+func (app *IsyApp) ArmElkArea(input *types.InputDiscoveryMessage, value string) error {
+	areaID := strings.TrimPrefix(app.isyAddress(input.NodeHWID), elkAreaHWID(""))
+	armType := value
+	if input.InputType == InputTypeDisarm {
+		armType = "disarm"
+	} else if armType == "" {
+		armType = "away"
+	}
+	code, _ := app.pub.GetNodeConfigString(input.NodeHWID, NodeAttrElkCode, "")
+	if code == "" {
+		err := fmt.Errorf("area %s has no Elk code configured", areaID)
+		logrus.Errorf("IsyApp.ArmElkArea: Input %s: %s", input.Address, err)
+		return err
+	}
+	logrus.Infof("IsyApp.ArmElkArea: Area %s: %s", areaID, armType)
+	err := app.isyAPI.WriteElkArm(areaID, armType, code)
+	if err != nil {
+		logrus.Errorf("IsyApp.ArmElkArea: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
@@ -0,0 +1,126 @@
+// Package internal for energy meters like the Insteon iMeter Solo and load-sensing modules
+package internal
+
+import (
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// InputTypeResetEnergy to reset the accumulated energy of a meter
+const InputTypeResetEnergy types.InputType = "resetEnergy"
+
+// energyControls maps the ISY energy controls to output types
+var energyControls = map[string]types.OutputType{
+	"CPW": types.OutputTypeElectricPower,   // current power
+	"TPW": types.OutputTypeElectricEnergy,  // total energy
+	"CV":  types.OutputTypeVoltage,         // voltage
+	"CC":  types.OutputTypeElectricCurrent, // current
+}
+
+// energyUOMs maps the ISY energy units of measure to output types
+// The iMeter reports its power as the ST property.
+var energyUOMs = map[string]types.OutputType{
+	"73": types.OutputTypeElectricPower,
+	"33": types.OutputTypeElectricEnergy,
+	"72": types.OutputTypeVoltage,
+	"1":  types.OutputTypeElectricCurrent,
+}
+
+// Insteon category and subcategory of the iMeter Solo 2423A1
+// Other devices in the energy management category, like the 2477SA1 load controller, are switches.
+const (
+	insteonEnergyCategory    = 0x09
+	insteonIMeterSubcategory = 0x07
+)
+
+// isEnergyMeter returns true if the ISY node is an energy meter
+// This is an Insteon iMeter or a node that reports power, energy, voltage or current properties.
+// Nodes whose main property is merely in an energy unit, like node server nodes reporting watts,
+// are not energy meters. They are described by their node definition.
+func isEnergyMeter(isyNode *IsyNode) bool {
+	category, subCategory := isyNode.InsteonType()
+	if category == insteonEnergyCategory && subCategory == insteonIMeterSubcategory {
+		return true
+	}
+	for _, prop := range append([]IsyProp{isyNode.Property}, isyNode.Properties...) {
+		if _, isEnergyControl := energyControls[prop.ID]; isEnergyControl {
+			return true
+		}
+	}
+	return false
+}
+
+// energyOutputType returns the output type of an energy meter property
+func (app *IsyApp) energyOutputType(prop IsyProp) types.OutputType {
+	if outputType, found := energyControls[prop.ID]; found {
+		return outputType
+	} else if outputType, found := energyUOMs[prop.UOM]; found {
+		return outputType
+	}
+	return app.controls.OutputType(prop.ID)
+}
+
+// updateEnergyMeter updates the energy meter node with the given properties
+// Each property is published as a numeric output with its unit.
+func (app *IsyApp) updateEnergyMeter(isyNode *IsyNode, props []IsyProp) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	if pub.GetNodeByHWID(nodeHWID) == nil {
+		pub.CreateNode(nodeHWID, types.NodeTypePowerMeter)
+		pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+			DataType:    types.DataTypeString,
+			Description: "Name of ISY node",
+			Default:     isyNode.Name,
+		})
+		pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+			types.NodeStatusRunState: types.NodeRunStateReady,
+		})
+		pub.CreateInput(nodeHWID, InputTypeResetEnergy, types.DefaultInputInstance, app.HandleInputCommand)
+	}
+	for _, prop := range props {
+		outputType := app.energyOutputType(prop)
+		outputValue, unit, _ := ConvertUOM(prop)
+		if outputValue == "" {
+			continue
+		}
+		if pub.GetOutputByNodeHWID(nodeHWID, outputType, types.DefaultOutputInstance) == nil {
+			output := pub.CreateOutput(nodeHWID, outputType, types.DefaultOutputInstance)
+			output.Description = app.controls.GetControl(prop.ID).Label
+			output.Unit = unit
+			pub.UpdateOutput(output)
+		}
+		pub.UpdateOutputValue(nodeHWID, outputType, types.DefaultOutputInstance, outputValue)
+	}
+}
+
+// UpdateEnergyMeters reads all properties of the energy meters and publishes them
+// The node list only contains the main property, so each meter's status is read separately.
+func (app *IsyApp) UpdateEnergyMeters() {
+	app.nodesMutex.Lock()
+	meters := make([]IsyNode, 0)
+	for _, isyNode := range app.isyNodes {
+		if isEnergyMeter(isyNode) {
+			meters = append(meters, *isyNode)
+		}
+	}
+	app.nodesMutex.Unlock()
+	for i := range meters {
+		isyNode := &meters[i]
+		nodeStatus, err := app.isyAPI.ReadIsyNodeStatus(isyNode.Address)
+		if err != nil {
+			logrus.Warningf("UpdateEnergyMeters: Error reading status of meter %s: %s", isyNode.Address, err)
+			continue
+		}
+		app.updateEnergyMeter(isyNode, nodeStatus.Properties)
+	}
+}
+
+// ResetEnergy resets the accumulated energy of the meter
+func (app *IsyApp) ResetEnergy(input *types.InputDiscoveryMessage) error {
+	logrus.Infof("IsyApp.ResetEnergy: Address %s", input.Address)
+	err := app.isyAPI.WriteResetEnergy(app.isyAddress(input.NodeHWID))
+	if err != nil {
+		logrus.Errorf("IsyApp.ResetEnergy: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
@@ -0,0 +1,113 @@
+// Package internal for the ISY gateway clock and system status
+package internal
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Gateway outputs that are not defined by iotdomain
+const (
+	// OutputTypeClock with the time of the ISY clock
+	OutputTypeClock types.OutputType = "clock"
+	// OutputTypeClockDrift with the seconds the ISY clock is ahead of the host clock
+	OutputTypeClockDrift types.OutputType = "clockDrift"
+	// OutputTypeClockDriftAlert is true when the clock drift exceeds the configured threshold
+	OutputTypeClockDriftAlert types.OutputType = "clockDriftAlert"
+	// OutputTypeSunrise with the time of sunrise today as calculated by the ISY
+	OutputTypeSunrise types.OutputType = "sunrise"
+	// OutputTypeSunset with the time of sunset today as calculated by the ISY
+	OutputTypeSunset types.OutputType = "sunset"
+	// OutputTypeSystemStatus with the ISY system status: ready, busy, idle or safeMode
+	OutputTypeSystemStatus types.OutputType = "systemStatus"
+	// OutputTypeUptime with the seconds since the ISY started
+	OutputTypeUptime types.OutputType = "uptime"
+)
+
+// DefaultClockDriftSec is the default clock drift after which the gateway is flagged
+const DefaultClockDriftSec = 60
+
+// systemStatusControl is the event stream control of the ISY system status
+const systemStatusControl = "_5"
+
+// SystemStatusNames with the names of the ISY system status event actions
+var SystemStatusNames = []string{"ready", "busy", "idle", "safeMode"}
+
+// updateGatewayOutput creates the gateway output if needed and updates its value
+func (app *IsyApp) updateGatewayOutput(outputType types.OutputType, unit types.Unit, value string) {
+	pub := app.pub
+	gwHWID := app.gatewayHWID()
+	if pub.GetOutputByNodeHWID(gwHWID, outputType, types.DefaultOutputInstance) == nil {
+		output := pub.CreateOutput(gwHWID, outputType, types.DefaultOutputInstance)
+		output.Unit = unit
+		pub.UpdateOutput(output)
+	}
+	pub.UpdateOutputValue(gwHWID, outputType, types.DefaultOutputInstance, value)
+}
+
+// UpdateGatewayStatus reads the ISY clock and publishes the clock, sun times and uptime
+// If the ISY clock drifts from the host clock by more than the configured threshold then the
+// clock drift alert is set, as well as the gateway lastError status, as ISY programs depend on
+// accurate time. They are cleared when the clock is back within the threshold.
+// The ISY REST API doesn't report its uptime or memory. The uptime is determined from the start
+// entry of the error log, see UpdateLogs, and is not published once that entry rolled out of the
+// log. Memory is not reported.
+// The system status is reported on the event stream. Until an event reports it, a gateway that
+// answers is published as ready.
+func (app *IsyApp) UpdateGatewayStatus() {
+	if app.pub.GetOutputByNodeHWID(app.gatewayHWID(), OutputTypeSystemStatus, types.DefaultOutputInstance) == nil {
+		app.updateGatewayOutput(OutputTypeSystemStatus, types.UnitNone, SystemStatusNames[0])
+	}
+	isyTime, err := app.isyAPI.ReadIsyTime()
+	if err != nil {
+		logrus.Warningf("UpdateGatewayStatus: Error reading ISY time: %s", err)
+		return
+	}
+	now := time.Now()
+	app.updateGatewayOutput(OutputTypeSunrise, types.UnitNone, isyTime.SunriseTime().Format(time.RFC3339))
+	app.updateGatewayOutput(OutputTypeSunset, types.UnitNone, isyTime.SunsetTime().Format(time.RFC3339))
+	clock := isyTime.Time()
+	if clock.IsZero() {
+		logrus.Warningf("UpdateGatewayStatus: Invalid ISY time '%s'", isyTime.NTP)
+		return
+	}
+	app.updateGatewayOutput(OutputTypeClock, types.UnitNone, clock.Format(time.RFC3339))
+	drift := int(math.Round(clock.Sub(now).Seconds()))
+	app.updateGatewayOutput(OutputTypeClockDrift, types.UnitSecond, fmt.Sprint(drift))
+	startTime := app.logs.StartTime(isyTime.Location())
+	if !startTime.IsZero() {
+		uptime := int(clock.Sub(startTime).Seconds())
+		app.updateGatewayOutput(OutputTypeUptime, types.UnitSecond, fmt.Sprint(uptime))
+	}
+
+	maxDrift := app.config.ClockDriftSec
+	if maxDrift <= 0 {
+		maxDrift = DefaultClockDriftSec
+	}
+	driftExceeded := drift > maxDrift || drift < -maxDrift
+	app.updateGatewayOutput(OutputTypeClockDriftAlert, types.UnitNone, fmt.Sprint(driftExceeded))
+	if driftExceeded {
+		// only log this once
+		if !app.clockDrifted {
+			logrus.Warningf("UpdateGatewayStatus: ISY clock drifts %d seconds from the host clock", drift)
+		}
+		app.pub.UpdateNodeStatus(app.gatewayHWID(), map[types.NodeStatus]string{
+			types.NodeStatusLastError: fmt.Sprintf("Gateway clock drifts %d seconds", drift),
+		})
+	} else if app.clockDrifted {
+		logrus.Infof("UpdateGatewayStatus: ISY clock is back within %d seconds of the host clock", maxDrift)
+		app.pub.UpdateNodeStatus(app.gatewayHWID(), map[types.NodeStatus]string{
+			types.NodeStatusLastError: "",
+		})
+	}
+	app.clockDrifted = driftExceeded
+}
+
+// handleSystemStatusEvent publishes the system status from the event stream
+func (app *IsyApp) handleSystemStatusEvent(event *IsyEvent) {
+	app.updateGatewayOutput(OutputTypeSystemStatus, types.UnitNone, stateName(SystemStatusNames, event.Action))
+}
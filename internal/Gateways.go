@@ -0,0 +1,154 @@
+// Package internal for running multiple ISY gateways in one publisher
+package internal
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/publisher"
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultPollIntervalSec is the default interval for polling a gateway
+const DefaultPollIntervalSec = 60
+
+// gatewayHWIDSeparator separates the gateway ID from the node address in a node HWID
+const gatewayHWIDSeparator = ":"
+
+// GatewayConfig with the configuration of one of multiple ISY gateways
+// The gateway ID is used as the gateway node ID and as the HWID prefix of the nodes of the gateway.
+type GatewayConfig struct {
+	ID              string           `yaml:"id"`             // gateway node ID, eg "building1"
+	GatewayAddress  string           `yaml:"gatewayAddress"` // gateway IP address or file:// for simulation
+	LoginName       string           `yaml:"login"`          // gateway login
+	Password        string           `yaml:"password"`       // gateway password
+	PollIntervalSec int              `yaml:"pollInterval"`   // default is DefaultPollIntervalSec
+	X10Units        []X10UnitConfig  `yaml:"x10"`            // X10 units to control through this gateway
+	CaptureFolder   string           `yaml:"captureFolder"`  // capture requests as simulation files in this folder
+	Simulation      SimulationFaults `yaml:"simulation"`     // faults to inject in simulation mode
+}
+
+// gatewayHWID returns the HWID of the gateway node
+// Without a gateway ID this is the default gateway node ID.
+func (app *IsyApp) gatewayHWID() string {
+	if app.gateway.ID == "" {
+		return types.NodeIDGateway
+	}
+	return app.gateway.ID
+}
+
+// nodeHWID returns the node HWID of an ISY node address in the namespace of the gateway
+// Without a gateway ID the address is used as is.
+func (app *IsyApp) nodeHWID(isyAddress string) string {
+	if app.gateway.ID == "" {
+		return isyAddress
+	}
+	return app.gateway.ID + gatewayHWIDSeparator + isyAddress
+}
+
+// isyAddress returns the ISY node address of a node HWID in the namespace of the gateway
+func (app *IsyApp) isyAddress(nodeHWID string) string {
+	if app.gateway.ID == "" {
+		return nodeHWID
+	}
+	return strings.TrimPrefix(nodeHWID, app.gateway.ID+gatewayHWIDSeparator)
+}
+
+// ownsNode returns true if the node HWID is the gateway node or in the namespace of the gateway
+func (app *IsyApp) ownsNode(nodeHWID string) bool {
+	return nodeHWID == app.gatewayHWID() || strings.HasPrefix(nodeHWID, app.gateway.ID+gatewayHWIDSeparator)
+}
+
+// IsyGateways with the apps of multiple gateways that share a publisher
+// Each gateway is polled on its own schedule so a gateway that is slow or unreachable does not
+// hold up the others.
+type IsyGateways struct {
+	apps     []*IsyApp
+	stopChan chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Apps returns the apps of the gateways
+func (gateways *IsyGateways) Apps() []*IsyApp {
+	return gateways.apps
+}
+
+// Start subscribes to the event stream of each gateway and starts polling them
+func (gateways *IsyGateways) Start() {
+	gateways.stopChan = make(chan struct{})
+	for _, app := range gateways.apps {
+		app.Start()
+		gateways.wg.Add(1)
+		go gateways.pollLoop(app)
+	}
+}
+
+// Stop stops polling and closes the event streams
+func (gateways *IsyGateways) Stop() {
+	if gateways.stopChan == nil {
+		return
+	}
+	close(gateways.stopChan)
+	gateways.wg.Wait()
+	gateways.stopChan = nil
+	for _, app := range gateways.apps {
+		app.Stop()
+	}
+}
+
+// HandleConfigCommand passes node configuration changes to the app of the gateway of the node
+func (gateways *IsyGateways) HandleConfigCommand(nodeHWID string, config types.NodeAttrMap) {
+	for _, app := range gateways.apps {
+		if app.ownsNode(nodeHWID) {
+			app.HandleConfigCommand(nodeHWID, config)
+			return
+		}
+	}
+	logrus.Warningf("IsyGateways.HandleConfigCommand: Node HWID '%s' is not a node of a gateway. Ignored", nodeHWID)
+}
+
+// pollLoop polls the gateway of the app until stopped
+func (gateways *IsyGateways) pollLoop(app *IsyApp) {
+	defer gateways.wg.Done()
+	interval := app.gateway.PollIntervalSec
+	if interval <= 0 {
+		interval = DefaultPollIntervalSec
+	}
+	logrus.Infof("IsyGateways.pollLoop: Polling gateway '%s' every %d seconds", app.gatewayHWID(), interval)
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+	app.Poll(app.pub)
+	for {
+		select {
+		case <-gateways.stopChan:
+			return
+		case <-ticker.C:
+			app.Poll(app.pub)
+		}
+	}
+}
+
+// NewIsyGateways creates an app for each of the configured gateways
+// Only one gateway can be configured without an address, as discovery finds the first ISY on the
+// local network. Other gateways without an address are ignored.
+func NewIsyGateways(config *IsyAppConfig, pub *publisher.Publisher) *IsyGateways {
+	gateways := &IsyGateways{apps: make([]*IsyApp, 0, len(config.Gateways))}
+	discoveredID := ""
+	for _, gateway := range config.Gateways {
+		if gateway.ID == "" {
+			logrus.Errorf("NewIsyGateways: Gateway with address '%s' has no ID. Ignored", gateway.GatewayAddress)
+			continue
+		} else if gateway.GatewayAddress == "" && discoveredID != "" {
+			logrus.Errorf("NewIsyGateways: Gateway '%s' has no address and gateway '%s' is already discovered. Ignored",
+				gateway.ID, discoveredID)
+			continue
+		} else if gateway.GatewayAddress == "" {
+			discoveredID = gateway.ID
+		}
+		gateways.apps = append(gateways.apps, newIsyApp(config, gateway, pub))
+	}
+	pub.SetNodeConfigHandler(gateways.HandleConfigCommand)
+	return gateways
+}
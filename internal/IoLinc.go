@@ -0,0 +1,184 @@
+// Package internal for the Insteon I/O Linc relay and sensor
+package internal
+
+import (
+	"strings"
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// I/O Linc node configuration attributes
+const (
+	// NodeAttrMomentaryMode with the relay mode, "latching" or "momentary"
+	NodeAttrMomentaryMode types.NodeAttr = "momentaryMode"
+	// NodeAttrMomentaryTime with the time in seconds the relay is on in momentary mode
+	NodeAttrMomentaryTime types.NodeAttr = "momentaryTime"
+	// NodeAttrSensorTriggersRelay with "true" to switch the relay when the sensor changes
+	NodeAttrSensorTriggersRelay types.NodeAttr = "sensorTriggersRelay"
+)
+
+// I/O Linc relay modes
+const (
+	MomentaryModeLatching  = "latching"
+	MomentaryModeMomentary = "momentary"
+)
+
+// DefaultMomentaryTimeSec is the default time the relay is on in momentary mode
+const DefaultMomentaryTimeSec = 2
+
+// The I/O Linc sensor is the primary node with group 1. The relay is a sub-node with group 2.
+const ioLincSensorGroup = "1"
+const ioLincRelayGroup = "2"
+
+// isIoLinc returns true if the ISY node is the sensor or relay node of an I/O Linc
+func isIoLinc(isyNode *IsyNode) bool {
+	category, subCategory := isyNode.InsteonType()
+	return category == 7 && subCategory == 0
+}
+
+// ioLincRelayAddress returns the ISY address of the relay node of an I/O Linc
+func ioLincRelayAddress(primaryAddress string) string {
+	parts := strings.Fields(primaryAddress)
+	if len(parts) == 0 {
+		return primaryAddress
+	}
+	parts[len(parts)-1] = ioLincRelayGroup
+	return strings.Join(parts, " ")
+}
+
+// updateIoLinc updates the I/O Linc node with its sensor and relay
+// The sensor is a read-only contact output. The relay is a switch that can be configured to be momentary.
+// The ISY REST API does not give access to the I/O Linc device options so momentary mode is
+// handled by the publisher. For a garage door opener set the mode to momentary.
+// Likewise the sensor triggers the relay when configured to do so: the relay follows the sensor
+// in latching mode, and closes momentarily when the sensor turns on in momentary mode.
+func (app *IsyApp) updateIoLinc(isyNode *IsyNode) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.PrimaryAddress())
+	if !isyNode.IsSubNode() && pub.GetNodeByHWID(nodeHWID) == nil {
+		pub.CreateNode(nodeHWID, types.NodeTypeOnOffSwitch)
+		pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+			DataType:    types.DataTypeString,
+			Description: "Name of ISY node",
+			Default:     isyNode.Name,
+		})
+		pub.UpdateNodeConfig(nodeHWID, NodeAttrMomentaryMode, &types.ConfigAttr{
+			DataType:    types.DataTypeEnum,
+			Description: "Relay mode",
+			Enum:        []string{MomentaryModeLatching, MomentaryModeMomentary},
+			Default:     MomentaryModeLatching,
+		})
+		pub.UpdateNodeConfig(nodeHWID, NodeAttrMomentaryTime, &types.ConfigAttr{
+			DataType:    types.DataTypeInt,
+			Description: "Time in seconds the relay is on in momentary mode",
+			Default:     "2",
+			Min:         1,
+			Max:         60,
+		})
+		pub.UpdateNodeConfig(nodeHWID, NodeAttrSensorTriggersRelay, &types.ConfigAttr{
+			DataType:    types.DataTypeBool,
+			Description: "The sensor switches the relay",
+			Default:     "false",
+		})
+		pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+			types.NodeStatusRunState: types.NodeRunStateReady,
+		})
+	}
+	if pub.GetNodeByHWID(nodeHWID) == nil {
+		logrus.Warningf("updateIoLinc: Primary node '%s' of I/O Linc node '%s' not found", nodeHWID, isyNode.Address)
+		return
+	}
+
+	switch isyNode.Group() {
+	case ioLincSensorGroup:
+		if pub.GetOutputByNodeHWID(nodeHWID, types.OutputTypeContact, types.DefaultOutputInstance) == nil {
+			pub.CreateOutput(nodeHWID, types.OutputTypeContact, types.DefaultOutputInstance)
+		}
+		sensorValue := isyOnOffValue(isyNode.Property.Value)
+		prevValue := pub.GetOutputValueByNodeHWID(nodeHWID, types.OutputTypeContact, types.DefaultOutputInstance)
+		pub.UpdateOutputValue(nodeHWID, types.OutputTypeContact, types.DefaultOutputInstance, sensorValue)
+		// only a change of the sensor triggers the relay, not the first poll
+		triggers, _ := pub.GetNodeConfigBool(nodeHWID, NodeAttrSensorTriggersRelay, false)
+		if triggers && prevValue != nil && prevValue.Value != sensorValue {
+			app.triggerRelay(nodeHWID, sensorValue == "true")
+		}
+	case ioLincRelayGroup:
+		if pub.GetOutputByNodeHWID(nodeHWID, types.OutputTypeRelay, types.DefaultOutputInstance) == nil {
+			pub.CreateOutput(nodeHWID, types.OutputTypeRelay, types.DefaultOutputInstance)
+			pub.CreateInput(nodeHWID, types.InputTypeRelay, types.DefaultInputInstance, app.HandleInputCommand)
+		}
+		outputValue := isyNode.Property.Value
+		// take value from simulation as the given node is a static file
+		if strings.HasPrefix(app.gateway.GatewayAddress, "file://") {
+			outputValue, _ = app.isyAPI.simulatedValue(isyNode.Address)
+		}
+		pub.UpdateOutputValue(nodeHWID, types.OutputTypeRelay, types.DefaultOutputInstance,
+			isyOnOffValue(outputValue))
+	}
+}
+
+// SwitchRelay turns the I/O Linc relay on or off
+// In momentary mode an 'on' command turns the relay off again after the configured momentary time.
+func (app *IsyApp) SwitchRelay(input *types.InputDiscoveryMessage, onOffString string) error {
+	newValue := isyOnOffValue(onOffString) == "true"
+	node := app.pub.GetNodeByAddress(input.Address)
+	err := app.switchRelay(node.HWID, newValue)
+	if err != nil {
+		logrus.Errorf("IsyApp.SwitchRelay: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
+
+// triggerRelay switches the relay when the sensor changes
+// In momentary mode only the sensor turning on triggers the relay.
+func (app *IsyApp) triggerRelay(nodeHWID string, sensorOn bool) {
+	mode, _ := app.pub.GetNodeConfigString(nodeHWID, NodeAttrMomentaryMode, MomentaryModeLatching)
+	if mode == MomentaryModeMomentary && !sensorOn {
+		return
+	}
+	logrus.Infof("IsyApp.triggerRelay: Sensor of I/O Linc '%s' triggers the relay", nodeHWID)
+	err := app.switchRelay(nodeHWID, sensorOn)
+	if err != nil {
+		logrus.Errorf("IsyApp.triggerRelay: I/O Linc '%s': error writing ISY: %v", nodeHWID, err)
+	}
+}
+
+// switchRelay turns the relay of the I/O Linc node on or off
+// A pending momentary release is cancelled, so the relay stays on for the full momentary time
+// after the last 'on' command and isn't turned off after a newer 'off' or latching command.
+func (app *IsyApp) switchRelay(nodeHWID string, newValue bool) error {
+	pub := app.pub
+	relayAddress := ioLincRelayAddress(app.isyAddress(nodeHWID))
+	mode, _ := pub.GetNodeConfigString(nodeHWID, NodeAttrMomentaryMode, MomentaryModeLatching)
+	logrus.Infof("IsyApp.switchRelay: Node %s. Mode=%s, New value=%v", nodeHWID, mode, newValue)
+
+	app.relayMutex.Lock()
+	defer app.relayMutex.Unlock()
+	if release := app.relayReleases[nodeHWID]; release != nil {
+		release.Stop()
+		delete(app.relayReleases, nodeHWID)
+	}
+	err := app.isyAPI.WriteOnOff(relayAddress, newValue)
+	if err != nil || !newValue || mode != MomentaryModeMomentary {
+		return err
+	}
+	momentaryTime, _ := pub.GetNodeConfigInt(nodeHWID, NodeAttrMomentaryTime, DefaultMomentaryTimeSec)
+	var release *time.Timer
+	release = time.AfterFunc(time.Duration(momentaryTime)*time.Second, func() {
+		app.relayMutex.Lock()
+		defer app.relayMutex.Unlock()
+		// a newer command has replaced this release
+		if app.relayReleases[nodeHWID] != release {
+			return
+		}
+		delete(app.relayReleases, nodeHWID)
+		err := app.isyAPI.WriteOnOff(relayAddress, false)
+		if err != nil {
+			logrus.Errorf("IsyApp.switchRelay: Node %s: error ending momentary on: %v", nodeHWID, err)
+		}
+	})
+	app.relayReleases[nodeHWID] = release
+	return nil
+}
@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"path"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -15,10 +17,15 @@ import (
 
 // IsyAPI gateway access
 type IsyAPI struct {
-	address    string            // ISY IP address or file:// for simulation
-	login      string            // Basic Auth login name
-	password   string            // Basic Auth password
-	simulation map[string]string // map used when in simulation
+	address       string            // ISY IP address or file:// for simulation
+	login         string            // Basic Auth login name
+	password      string            // Basic Auth password
+	simulation    map[string]string // map used when in simulation
+	simMutex      sync.Mutex        // guards simulation, commands can be written from timers
+	captureFolder string            // folder to capture requests in, see SetCaptureFolder
+	captureMutex  sync.Mutex
+	simulator     simulator   // faults to inject in simulation mode
+	firmware      IsyFirmware // firmware version of the gateway, set by ReadIsyGateway
 }
 
 // IsyDevice Collection of ISY99x device information from multiple ISY REST calls
@@ -47,6 +54,18 @@ type IsyAPI struct {
 //        <id>1020</id>
 //        <desc>ISY 99i 256</desc>
 //    </product>
+//    <features>
+//        <feature>
+//            <id>21040</id>
+//            <desc>Networking Module</desc>
+//            <isInstalled>false</isInstalled>
+//            <isAvailable>true</isAvailable>
+//        </feature>
+//    </features>
+//    <triggers>true</triggers>
+//    <variables>true</variables>
+//    <secsys type="ELK" version="2"/>
+//    <security>SSL</security>
 //    ...
 // </configuration>
 type IsyDevice struct {
@@ -66,6 +85,17 @@ type IsyDevice struct {
 			ID          string `xml:"id"`
 			Description string `xml:"desc"`
 		} `xml:"product"`
+		Controls  []IsyControl `xml:"controls>control"`
+		Features  []IsyFeature `xml:"features>feature"`
+		Triggers  bool         `xml:"triggers"`  // programs are supported
+		Variables bool         `xml:"variables"` // variables are supported
+		SecSys    struct {
+			Type    string `xml:"type,attr"` // ELK if the Elk module is present
+			Version string `xml:"version,attr"`
+		} `xml:"secsys"`
+		Security       string `xml:"security"` // SSL if enabled
+		IsDefaultCert  bool   `xml:"isDefaultCert"`
+		MaxSSLStrength string `xml:"maxSSLStrength"`
 	} `xml:"configuration"`
 	// network struct {
 	// 	Interface struct {
@@ -78,6 +108,36 @@ type IsyDevice struct {
 	// }
 }
 
+// IsyControl with the definition of a control (property or command) supported by the gateway. Example:
+// <control>
+//    <name>ST</name>
+//    <label>Status</label>
+//    <readOnly>true</readOnly>
+//    <isQueryAble>true</isQueryAble>
+//    <isNumeric>true</isNumeric>
+//    <numericUnit>%</numericUnit>
+// </control>
+type IsyControl struct {
+	Name        string `xml:"name"`
+	Label       string `xml:"label"`
+	ReadOnly    bool   `xml:"readOnly"`
+	IsQueryAble bool   `xml:"isQueryAble"`
+	IsNumeric   bool   `xml:"isNumeric"`
+	NumericUnit string `xml:"numericUnit"`
+	Actions     []struct {
+		Name  string `xml:"name"`
+		Label string `xml:"label"`
+	} `xml:"actions>action"`
+}
+
+// IsyFeature with a gateway module and whether it is installed
+type IsyFeature struct {
+	ID          string `xml:"id"`
+	Description string `xml:"desc"`
+	IsInstalled bool   `xml:"isInstalled"`
+	IsAvailable bool   `xml:"isAvailable"`
+}
+
 // IsyNodes Collection of ISY99x nodes. Example:
 // <nodes>
 //    <root>Nodes</root>
@@ -91,20 +151,175 @@ type IsyDevice struct {
 //        <ELK_ID>A04</ELK_ID>
 //        <property id="ST" value="255" formatted="On" uom="on/off"/>
 //    </node>
+//    <node flag="128" nodeDefId="ZY002_153">    (firmware 5.x)
+//        <address>ZW002_1</address>
+//        <name>Front door lock</name>
+//        <family>4</family>
+//        <type>4.64.3.0</type>
+//        <property id="ST" value="100" formatted="Locked" uom="11"/>
+//    </node>
+//    <group flag="132">
+//        <address>28614</address>
+//        <name>Outside lights</name>
+//        <members>
+//            <link type="16">15 2D A 1</link>
+//        </members>
+//    </group>
 type IsyNodes struct {
-	// ignore the folder names
-	Nodes []*IsyNode `xml:"node"`
+	Folders []*IsyFolder `xml:"folder"`
+	Nodes   []*IsyNode   `xml:"node"`
+	Groups  []*IsyGroup  `xml:"group"`
+}
+
+// IsyFolder with a folder that organizes nodes
+type IsyFolder struct {
+	Address string `xml:"address"`
+	Name    string `xml:"name"`
+}
+
+// IsyGroup with a scene and its member nodes
+// The group with flag 12 is the ISY itself, which has all nodes as members.
+type IsyGroup struct {
+	Flag    int      `xml:"flag,attr"`
+	Address string   `xml:"address"`
+	Name    string   `xml:"name"`
+	Parent  string   `xml:"parent"`
+	Members []string `xml:"members>link"`
+}
+
+// IsScene returns true if the group is a scene rather than the ISY root group
+func (group *IsyGroup) IsScene() bool {
+	return group.Flag&0x08 == 0
+}
+
+// ISY node families. Nodes without a family are Insteon nodes.
+const (
+	FamilyInsteon    = "1"
+	FamilyUPB        = "2"
+	FamilyRCS        = "3"
+	FamilyZWave      = "4"
+	FamilyAutoDR     = "5"
+	FamilyGroup      = "6"
+	FamilyBrultech   = "7"
+	FamilyNCD        = "8"
+	FamilyNodeServer = "10"
+	FamilyZMatter    = "12"
+)
+
+// IsyFamily with the family of a node, firmware 4.x and up
+// The instance is the slot of the node server for nodes of the node server family.
+type IsyFamily struct {
+	ID       string `xml:",chardata"`
+	Instance string `xml:"instance,attr"`
 }
 
 // IsyNode with info of a node on the gateway
 type IsyNode struct {
-	Address  string  `xml:"address"`
-	Name     string  `xml:"name"`
-	Parent   string  `xml:"parent"`
-	Type     string  `xml:"type"`
-	Enabled  string  `xml:"enabled"`
-	Pnode    string  `xml:"pnode"`
-	Property IsyProp `xml:"property"`
+	Address   string    `xml:"address"`
+	Name      string    `xml:"name"`
+	Parent    string    `xml:"parent"`
+	Type      string    `xml:"type"`
+	Enabled   string    `xml:"enabled"`
+	Pnode     string    `xml:"pnode"`
+	ElkID     string    `xml:"ELK_ID"`
+	Family    IsyFamily `xml:"family"`
+	NodeDefID string    `xml:"nodeDefId,attr"` // node definition, firmware 5.x
+	// Properties with all properties of the node. Firmware before 5.x only reports the status.
+	Properties []IsyProp `xml:"property"`
+	// Property is the main property of the node, ST if present
+	Property IsyProp `xml:"-"`
+}
+
+// FamilyID returns the family of the node, FamilyInsteon if the firmware doesn't report it
+func (isyNode *IsyNode) FamilyID() string {
+	if isyNode.Family.ID == "" || isyNode.Family.ID == "0" {
+		return FamilyInsteon
+	}
+	return isyNode.Family.ID
+}
+
+// InsteonType returns the Insteon device category and subcategory of the node
+// The ISY node type has the format "category.subcategory.version.reserved", eg 2.12.56.0
+// This returns 0, 0 if the type is not recognized or the node is not an Insteon node
+func (isyNode *IsyNode) InsteonType() (category int, subCategory int) {
+	// other families, like Z-Wave, use the same format with a different meaning
+	if isyNode.FamilyID() != FamilyInsteon {
+		return 0, 0
+	}
+	parts := strings.Split(isyNode.Type, ".")
+	if len(parts) < 2 {
+		return 0, 0
+	}
+	category, _ = strconv.Atoi(parts[0])
+	subCategory, _ = strconv.Atoi(parts[1])
+	return category, subCategory
+}
+
+// ZWaveGenericClass returns the Z-Wave generic device class of the node, eg 0x40 for entry control
+// Z-Wave nodes have the type "4.genericClass.specificClass.reserved", eg 4.64.3.0
+// This returns 0 if the type is not recognized or the node is not a Z-Wave node
+func (isyNode *IsyNode) ZWaveGenericClass() int {
+	if family := isyNode.FamilyID(); family != FamilyZWave && family != FamilyZMatter {
+		return 0
+	}
+	parts := strings.Split(isyNode.Type, ".")
+	if len(parts) < 2 {
+		return 0
+	}
+	genericClass, _ := strconv.Atoi(parts[1])
+	return genericClass
+}
+
+// Group returns the group number of the node, which is the last part of its address
+// Devices with multiple nodes, like keypads and sensors, use the group to identify their sub-nodes.
+// eg "13 55 D3 3" is group 3.
+func (isyNode *IsyNode) Group() string {
+	parts := strings.Fields(isyNode.Address)
+	if len(parts) == 0 {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// PrimaryAddress returns the address of the primary node of the device this node belongs to
+func (isyNode *IsyNode) PrimaryAddress() string {
+	if isyNode.Pnode == "" {
+		return isyNode.Address
+	}
+	return isyNode.Pnode
+}
+
+// setProperty sets the value of a property of the node
+// The unit of measure and precision are kept unless the given property has them, as events of
+// older firmware only report the value.
+// This returns false if the node doesn't have the property. The properties are copied so the
+// node can be a copy of a node that is in use.
+func (isyNode *IsyNode) setProperty(prop IsyProp) bool {
+	found := false
+	if isyNode.Property.ID == prop.ID {
+		isyNode.Property.update(prop)
+		found = true
+	}
+	for i := range isyNode.Properties {
+		if isyNode.Properties[i].ID == prop.ID {
+			isyNode.Properties = append([]IsyProp{}, isyNode.Properties...)
+			isyNode.Properties[i].update(prop)
+			return true
+		}
+	}
+	return found
+}
+
+// setStatus sets the status property of the node, ST, and adds it if the node has not reported it
+func (isyNode *IsyNode) setStatus(prop IsyProp) {
+	if !isyNode.setProperty(prop) {
+		isyNode.Property = prop
+	}
+}
+
+// IsSubNode returns true if this node is a secondary node of a device with multiple nodes
+func (isyNode *IsyNode) IsSubNode() bool {
+	return isyNode.PrimaryAddress() != isyNode.Address
 }
 
 // IsyStatus with status as returned by the controller. Example:
@@ -116,10 +331,12 @@ type IsyNode struct {
 //        <property id="ST" value="255" formatted="On" uom="on/off"/>
 //    </node>
 //    ...
+// Firmware 5.x reports all properties of a node, older firmware only the status.
 type IsyStatus struct {
 	Nodes []struct {
-		Address string  `xml:"id,attr"`  // The ID attribute is the actual ISY node address
-		Prop    IsyProp `xml:"property"` // TODO: Can a node haves multiple properties with their values?
+		Address string    `xml:"id,attr"` // The ID attribute is the actual ISY node address
+		Props   []IsyProp `xml:"property"`
+		Prop    IsyProp   `xml:"-"` // The main property, ST if present
 	} `xml:"node"`
 }
 
@@ -129,65 +346,200 @@ type IsyProp struct {
 	Value     string `xml:"value,attr"` //
 	Formatted string `xml:"formatted,attr"`
 	UOM       string `xml:"uom,attr"`
+	Precision string `xml:"prec,attr"` // number of decimals in value, firmware 4.x and up
+}
+
+// update the value of the property, and the unit of measure and precision if given
+// The formatted value is replaced as it no longer matches the value.
+func (prop *IsyProp) update(newProp IsyProp) {
+	prop.Value = newProp.Value
+	prop.Formatted = newProp.Formatted
+	if newProp.UOM != "" {
+		prop.UOM = newProp.UOM
+		prop.Precision = newProp.Precision
+	}
 }
 
 // ReadIsyStatus reads the ISY Node status
 func (isyAPI *IsyAPI) ReadIsyStatus() (*IsyStatus, error) {
-	isyStatus := IsyStatus{}
-	err := isyAPI.isyRequest("/rest/status", &isyStatus)
-	return &isyStatus, err
+	buffer, err := isyAPI.isyRequestRaw("/rest/status")
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyStatus(buffer)
+}
+
+// IsyNodeStatus with the status properties of a single node
+type IsyNodeStatus struct {
+	Properties []IsyProp `xml:"property"`
+}
+
+// ReadIsyNodeStatus reads the status of a single node
+// Returns the status properties of the node, eg ST
+func (isyAPI *IsyAPI) ReadIsyNodeStatus(deviceID string) (*IsyNodeStatus, error) {
+	buffer, err := isyAPI.isyRequestRaw(fmt.Sprintf("/rest/status/%s", deviceID))
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyNodeStatus(buffer)
 }
 
 // ReadIsyNodes reads the ISY Node list
 func (isyAPI *IsyAPI) ReadIsyNodes() (*IsyNodes, error) {
-	isyNodes := IsyNodes{}
-
-	err := isyAPI.isyRequest("/rest/nodes", &isyNodes)
-	return &isyNodes, err
+	buffer, err := isyAPI.isyRequestRaw("/rest/nodes")
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyNodes(buffer)
 }
 
 // ReadIsyGateway reads ISY gateway configuration and status
 // returns isyDevice with device information
 func (isyAPI *IsyAPI) ReadIsyGateway() (isyDevice *IsyDevice, err error) {
-	isyDevice = &IsyDevice{}
-	err = isyAPI.isyRequest("/rest/config", &isyDevice.Configuration)
+	buffer, err := isyAPI.isyRequestRaw("/rest/config")
+	if err != nil {
+		return nil, err
+	}
+	isyDevice, err = ParseIsyConfig(buffer)
+	if err == nil {
+		isyAPI.firmware = isyDevice.Firmware()
+	}
 	return isyDevice, err
 }
 
+// Firmware returns the firmware version of the gateway as last read with ReadIsyGateway
+func (isyAPI *IsyAPI) Firmware() IsyFirmware {
+	return isyAPI.firmware
+}
+
+// ReadIsyProperty reads the current value of a single node property
+// Firmware 5.x reads the property with the get command, older firmware reads it from the node status.
+func (isyAPI *IsyAPI) ReadIsyProperty(deviceID string, propertyID string) (*IsyProp, error) {
+	if isyAPI.firmware.hasPropertyCommands() {
+		buffer, err := isyAPI.isyRequestRaw(fmt.Sprintf("/rest/nodes/%s/get/%s", deviceID, propertyID))
+		if err != nil {
+			return nil, err
+		}
+		return ParseIsyProp(buffer)
+	}
+	nodeStatus, err := isyAPI.ReadIsyNodeStatus(deviceID)
+	if err != nil {
+		return nil, err
+	}
+	for _, prop := range nodeStatus.Properties {
+		if prop.ID == propertyID {
+			return &prop, nil
+		}
+	}
+	return nil, fmt.Errorf("ReadIsyProperty: Node %s has no property %s", deviceID, propertyID)
+}
+
 // WriteOnOff writes an on or off command to an isy node
 // deviceID is the ISY node ID
 // onOff is the new value to write
 func (isyAPI *IsyAPI) WriteOnOff(deviceID string, onOff bool) error {
-	var err error
 	newValue := "DON"
 	if onOff == false {
 		newValue = "DOF"
 	}
-	// default to last set value
-	isyAPI.simulation[deviceID] = newValue
-	// can't request this in simulation mode
-	if !strings.HasPrefix(isyAPI.address, "file://") {
-		restPath := fmt.Sprintf("/rest/nodes/%s/cmd/%s", deviceID, newValue)
-		err = isyAPI.isyRequest(restPath, nil)
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/%s", deviceID, newValue)
+	return isyAPI.writeCommand(restPath, deviceID, newValue)
+}
+
+// WriteLevel turns a dimmer on to the given level
+// deviceID is the ISY node ID
+// level is the on level in the range 0-255
+func (isyAPI *IsyAPI) WriteLevel(deviceID string, level int) error {
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/DON/%d", deviceID, level)
+	return isyAPI.writeCommand(restPath, deviceID, strconv.Itoa(level))
+}
+
+// WriteLevelPercent turns a dimmer on to the given level or off
+// Unlike Insteon dimmers, Z-Wave and node server dimmers take the level as a percentage.
+// deviceID is the ISY node ID
+// percent is the level in the range 0-100, where 0 turns the dimmer off
+func (isyAPI *IsyAPI) WriteLevelPercent(deviceID string, percent int) error {
+	if percent > 100 {
+		percent = 100
 	}
-	return err
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/DON/%d", deviceID, percent)
+	if percent <= 0 {
+		percent = 0
+		restPath = fmt.Sprintf("/rest/nodes/%s/cmd/DOF", deviceID)
+	}
+	return isyAPI.writeCommand(restPath, deviceID, strconv.Itoa(percent))
 }
 
-// isyRequest sends a request to the ISY device
+// WriteProperty writes a new value of a node property, like the on level or ramp rate
+// deviceID is the ISY node ID
+// propertyID is the control name of the property, eg OL
+// value is the new raw ISY value
+// Firmware 5.x uses the set command, older firmware sends the property as a command.
+func (isyAPI *IsyAPI) WriteProperty(deviceID string, propertyID string, value string) error {
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/%s/%s", deviceID, propertyID, value)
+	if isyAPI.firmware.hasPropertyCommands() {
+		restPath = fmt.Sprintf("/rest/nodes/%s/set/%s/%s", deviceID, propertyID, value)
+	}
+	return isyAPI.writeCommand(restPath, deviceID+"/"+propertyID, value)
+}
+
+// WriteResetEnergy resets the accumulated energy of an energy meter, like the iMeter Solo
+// deviceID is the ISY node ID
+func (isyAPI *IsyAPI) WriteResetEnergy(deviceID string) error {
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/RESET", deviceID)
+	return isyAPI.writeCommand(restPath, deviceID+"/TPW", "0")
+}
+
+// WriteX10 sends an X10 command through the ISY
+// x10Address is the house and unit code, eg A1
+// x10Cmd is the X10 command code, eg X10CmdOn
+func (isyAPI *IsyAPI) WriteX10(x10Address string, x10Cmd string) error {
+	restPath := fmt.Sprintf("/rest/X10/%s/%s", x10Address, x10Cmd)
+	return isyAPI.writeCommand(restPath, x10Address, x10Cmd)
+}
+
+// Query requests the ISY to query the device for its current status
+// deviceID is the ISY node ID. An empty ID queries all devices, which can take several minutes.
+func (isyAPI *IsyAPI) Query(deviceID string) error {
+	restPath := "/rest/query"
+	if deviceID != "" {
+		restPath = fmt.Sprintf("/rest/query/%s", deviceID)
+	}
+	return isyAPI.writeCommand(restPath, "", "")
+}
+
+// isyRequest sends a request to the ISY device and decodes the XML response
 // address contains the gateway address. If it starts with file:// then read from
 // (simulation) file named <address>/<restPath>.xml
 // restPath contains the REST url path for the request
+// response is decoded from the XML response. Use nil to ignore the response.
 func (isyAPI *IsyAPI) isyRequest(restPath string, response interface{}) error {
+	buffer, err := isyAPI.isyRequestRaw(restPath)
+	if err != nil || response == nil {
+		return err
+	}
+	err = xml.Unmarshal(buffer, response)
+	return err
+}
+
+// isyRequestRaw sends a request to the ISY device and returns the response body
+// Simulation files use the same file name as XML responses, <address>/<restPath>.xml, where
+// query parameters are not part of the file name.
+func (isyAPI *IsyAPI) isyRequestRaw(restPath string) ([]byte, error) {
 	// if address is a file then load content from file. Intended for testing
 	if strings.HasPrefix(isyAPI.address, "file://") {
-		filename := path.Join(isyAPI.address[7:], restPath+".xml")
+		err := isyAPI.simulator.request(restPath)
+		if err != nil {
+			logrus.Warn(err)
+			return nil, err
+		}
+		filename := SimulationFilename(isyAPI.address[7:], restPath)
 		buffer, err := ioutil.ReadFile(filename)
 		if err != nil {
 			logrus.Errorf("isyRequest: Unable to read ISY data from file from %s: %v", filename, err)
-			return err
+			return nil, err
 		}
-		err = xml.Unmarshal(buffer, &response)
-		return err
+		return isyAPI.simulator.response(restPath, buffer), nil
 	}
 
 	// not a file, continue with http request
@@ -195,30 +547,41 @@ func (isyAPI *IsyAPI) isyRequest(restPath string, response interface{}) error {
 	req, err := http.NewRequest("GET", isyURL, nil)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.SetBasicAuth(isyAPI.login, isyAPI.password)
 	client := &http.Client{}
 	resp, err := client.Do(req)
 
 	if err != nil {
-		logrus.Warnf("pollDevice: Unable to read ISY device from %s: %v", isyURL, err)
-		return err
-	} else if resp.StatusCode != 200 {
-		msg := fmt.Sprintf("pollDevice: Error code return by ISY device %s: %v", isyURL, resp.Status)
+		// the error includes the URL
+		if urlErr, isURLErr := err.(*url.Error); isURLErr {
+			urlErr.URL = redactQuery(urlErr.URL)
+		}
+		logrus.Warnf("pollDevice: Unable to read ISY device from %s: %v", redactQuery(isyURL), err)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		msg := fmt.Sprintf("pollDevice: Error code return by ISY device %s: %v", redactQuery(isyURL), resp.Status)
 		logrus.Warn(msg)
 		err = errors.New(msg)
-		return err
+		return nil, err
 	}
-
-	// Decode the response into XML
-	if response != nil {
-		dec := xml.NewDecoder(resp.Body)
-		_ = dec.Decode(&response)
-		_ = resp.Body.Close()
+	buffer, err := ioutil.ReadAll(resp.Body)
+	if err == nil {
+		isyAPI.capture(restPath, buffer)
 	}
+	return buffer, err
+}
 
-	return nil
+// redactQuery returns the path or URL with its query parameters removed, for use in logs and errors
+// Query parameters can hold secrets, like the user code of Elk commands.
+func redactQuery(restPath string) string {
+	if i := strings.Index(restPath, "?"); i >= 0 {
+		return restPath[:i] + "?..."
+	}
+	return restPath
 }
 
 // NewIsyAPI create an ISY API proxy
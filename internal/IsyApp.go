@@ -4,6 +4,10 @@ package internal
 
 import (
 	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/iotdomain/iotdomain-go/publisher"
@@ -14,6 +18,16 @@ import (
 // ConfigDefaultPollIntervalSec for polling the gateway
 const ConfigDefaultPollIntervalSec = 15 * 60
 
+// Gateway node attributes with the ISY modules and capabilities
+const (
+	NodeAttrInstalledFeatures types.NodeAttr = "installedFeatures"
+	NodeAttrAvailableFeatures types.NodeAttr = "availableFeatures"
+	NodeAttrControls          types.NodeAttr = "controls"
+	NodeAttrSecurity          types.NodeAttr = "security"
+	NodeAttrSecuritySystem    types.NodeAttr = "securitySystem"
+	NodeAttrSubsystems        types.NodeAttr = "subsystems"
+)
+
 // AppID application name used for configuration file and default publisherID
 const appID = "isy99"
 
@@ -23,21 +37,66 @@ type IsyAppConfig struct {
 	LoginName      string `yaml:"login"`          // gateway login
 	Password       string `yaml:"password"`       // gateway password
 	PublisherID    string `yaml:"publisherId"`    // default is app ID
+	// SensorTimeoutSec is the time without reports after which a battery powered sensor is flagged
+	SensorTimeoutSec int `yaml:"sensorTimeoutSec"` // default is DefaultSensorTimeoutSec
+	// PublishFormatted also publishes output values as formatted by the ISY
+	PublishFormatted bool `yaml:"publishFormatted"`
+	// X10Units with the X10 units to control through the ISY
+	X10Units []X10UnitConfig `yaml:"x10"`
+	// QueryTime is the daily time, hh:mm, to query all devices to resync the ISY status
+	QueryTime string `yaml:"queryTime"` // default is no scheduled query
+	// ClockDriftSec is the difference between the gateway and host clock after which the gateway is flagged
+	ClockDriftSec int `yaml:"clockDriftSec"` // default is DefaultClockDriftSec
+	// LogHistorySize is the number of recent ISY error and event log entries to keep
+	LogHistorySize int `yaml:"logHistorySize"` // default is DefaultLogHistorySize
+	// CaptureFolder captures the gateway requests and responses as simulation files in this folder
+	CaptureFolder string `yaml:"captureFolder"` // default is no capture
+	// Simulation with the faults to inject when the gateway address is a file:// simulation
+	Simulation SimulationFaults `yaml:"simulation"`
+	// Gateways with multiple gateways to access. This replaces the single gateway settings above.
+	Gateways []GatewayConfig `yaml:"gateways"`
 }
 
 // IsyApp adapter main class
-// to access multiple gatewways, run additional instances, or modify this code for multiple isyAPI instances
+// Each IsyApp accesses a single gateway. Multiple gateways are accessed using IsyGateways.
 type IsyApp struct {
-	config *IsyAppConfig
-	pub    *publisher.Publisher
-	isyAPI *IsyAPI // ISY gateway access
+	config         *IsyAppConfig
+	gateway        GatewayConfig // the gateway accessed by this app
+	pub            *publisher.Publisher
+	isyAPI         *IsyAPI                // ISY gateway access
+	isyDevice      *IsyDevice             // last read ISY gateway configuration
+	controls       *ControlRegistry       // control definitions from the gateway configuration
+	isyNodes       map[string]*IsyNode    // last read ISY nodes by address
+	sensorLastSeen map[string]time.Time   // time battery powered sensors last reported, by node HWID
+	nodeDefs       *IsyNodeDefs           // node definitions of firmware 5.x, nil if not read
+	nodeDefIDs     map[string]bool        // node definition IDs of the nodes when nodeDefs was read
+	nodeServers    map[string]*nodeServer // node servers by profile slot, firmware 5.x
+	profileRetries map[string]loadRetry   // next attempt to load node server profiles that failed, by slot
+	profilesRead   time.Time              // time the node servers were last read
+	zwaveKinds     map[string]zwaveDevice // kind of device of the published Z-Wave nodes, by node HWID
+	nodesMutex     sync.Mutex             // mutex for access to isyNodes, sensorLastSeen, nodeDefs, nodeServers, profileRetries and zwaveKinds
+	relayReleases  map[string]*time.Timer // pending release of momentary I/O Linc relays, by node HWID
+	relayMutex     sync.Mutex             // mutex for switching relays and access to relayReleases
+	eventStream    io.Closer              // subscription to the ISY event stream
+	lastQueryAll   time.Time              // time all devices were last queried
+	clockDrifted   bool                   // the gateway clock drifts more than the configured threshold
+	readFailures   int                    // number of consecutive failed reads of the gateway
+	discoveredMAC  string                 // MAC of the discovered gateway, empty if not yet read
+	logs           *IsyLogs               // recent ISY error and event log entries
 }
 
 // ReadGateway reads the isy99 gateway device and its nodes
 // This returns the ID of the gateway node that was read
 func (app *IsyApp) ReadGateway() (gwHWID string, err error) {
 	pub := app.pub
-	gwHWID = types.NodeIDGateway
+	gwHWID = app.gatewayHWID()
+	// without a configured address the gateway is discovered on the local network
+	if app.gateway.GatewayAddress == "" && app.isyAPI.address == "" {
+		err = app.discoverGateway()
+		if err != nil {
+			return gwHWID, err
+		}
+	}
 	startTime := time.Now()
 	isyDevice, err := app.isyAPI.ReadIsyGateway()
 	endTime := time.Now()
@@ -54,15 +113,37 @@ func (app *IsyApp) ReadGateway() (gwHWID string, err error) {
 				types.NodeStatusLastError: "Gateway not reachable on address " + app.isyAPI.address,
 			})
 		}
+		// a discovered gateway might have moved, eg by DHCP, so discover it again when it keeps failing
+		app.readFailures++
+		if app.gateway.GatewayAddress == "" && app.readFailures >= RediscoverAfterFailures {
+			logrus.Warningf("IsyApp.ReadGateway: Rediscovering the ISY gateway after %d failed reads", app.readFailures)
+			app.isyAPI.address = ""
+		}
 		return gwHWID, err
 	}
+	app.readFailures = 0
+	// another device can get the address of a discovered gateway
+	mac := isyDevice.Configuration.Root.ID
+	if app.gateway.GatewayAddress == "" && app.discoveredMAC != "" && mac != app.discoveredMAC {
+		logrus.Warningf("IsyApp.ReadGateway: Device %s at address %s is not the discovered ISY gateway %s",
+			mac, app.isyAPI.address, app.discoveredMAC)
+		app.isyAPI.address = ""
+		return gwHWID, fmt.Errorf("device at address of the ISY gateway has changed")
+	}
+	app.discoveredMAC = mac
+	app.isyDevice = isyDevice
+	app.controls.Load(isyDevice.Configuration.Controls)
 
-	pub.UpdateNodeStatus(gwHWID, map[types.NodeStatus]string{
+	status := map[types.NodeStatus]string{
 		types.NodeStatusRunState:    types.NodeRunStateReady,
-		types.NodeStatusLastError:   "Connection restored to address " + app.isyAPI.address,
 		types.NodeStatusLatencyMSec: fmt.Sprintf("%d", latency.Milliseconds()),
-	})
-	logrus.Warningf("Isy99Adapter.ReadGateway: Connection restored to ISY99x gateway on address %s", app.isyAPI.address)
+	}
+	// only report this once so it doesn't replace other errors, like the clock drift
+	if prevStatus != types.NodeRunStateReady {
+		status[types.NodeStatusLastError] = "Connection restored to address " + app.isyAPI.address
+		logrus.Warningf("Isy99Adapter.ReadGateway: Connection restored to ISY99x gateway on address %s", app.isyAPI.address)
+	}
+	pub.UpdateNodeStatus(gwHWID, status)
 
 	// Update the info we have on the gateway
 	pub.UpdateNodeAttr(gwHWID, map[types.NodeAttr]string{
@@ -71,16 +152,37 @@ func (app *IsyApp) ReadGateway() (gwHWID string, err error) {
 		types.NodeAttrModel:           isyDevice.Configuration.Product.Description,
 		types.NodeAttrManufacturer:    isyDevice.Configuration.DeviceSpecs.Make,
 		// types.NodeAttrLocalIP:         isyDevice.network.Interface.IP,
-		types.NodeAttrLocalIP: app.isyAPI.address,
+		types.NodeAttrLocalIP: addressIP(app.isyAPI.address),
 		types.NodeAttrMAC:     isyDevice.Configuration.Root.ID,
 	})
+	app.updateGatewayCapabilities(gwHWID, isyDevice)
 	return gwHWID, nil
 }
 
+// updateGatewayCapabilities publishes the installed modules, controls and security settings of the gateway
+func (app *IsyApp) updateGatewayCapabilities(gwHWID string, isyDevice *IsyDevice) {
+	controls := make([]string, 0)
+	for _, control := range isyDevice.Configuration.Controls {
+		controls = append(controls, control.Name)
+	}
+	securitySystem := ""
+	if isyDevice.Configuration.SecSys.Type != "" {
+		securitySystem = isyDevice.Configuration.SecSys.Type + " v" + isyDevice.Configuration.SecSys.Version
+	}
+	app.pub.UpdateNodeAttr(gwHWID, map[types.NodeAttr]string{
+		NodeAttrInstalledFeatures: strings.Join(isyDevice.InstalledFeatures(), ", "),
+		NodeAttrAvailableFeatures: strings.Join(isyDevice.AvailableFeatures(), ", "),
+		NodeAttrControls:          strings.Join(controls, ","),
+		NodeAttrSecurity:          isyDevice.Configuration.Security,
+		NodeAttrSecuritySystem:    securitySystem,
+		NodeAttrSubsystems:        strings.Join(isyDevice.Subsystems().Names(), ","),
+	})
+}
+
 // SetupGatewayNode creates the gateway node if it doesn't exist
 // This set the default gateway address in its configuration
 func (app *IsyApp) SetupGatewayNode(pub *publisher.Publisher) {
-	gwID := types.NodeIDGateway
+	gwID := app.gatewayHWID()
 	logrus.Infof("SetupGatewayNode. ID=%s", gwID)
 
 	gatewayNode := pub.GetNodeByHWID(gwID)
@@ -103,23 +205,110 @@ func (app *IsyApp) SetupGatewayNode(pub *publisher.Publisher) {
 		Description: "ISY gateway login password",
 		Secret:      true,
 	})
+	if pub.GetInputByNodeHWID(gwID, InputTypeReadLinks, types.DefaultInputInstance) == nil {
+		pub.CreateInput(gwID, InputTypeReadLinks, types.DefaultInputInstance, app.HandleInputCommand)
+	}
+	if pub.GetInputByNodeHWID(gwID, InputTypeQuery, types.DefaultInputInstance) == nil {
+		pub.CreateInput(gwID, InputTypeQuery, types.DefaultInputInstance, app.HandleInputCommand)
+	}
+}
+
+// addressIP returns the IP address or hostname of a gateway address without its port
+func addressIP(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+// discoverGateway discovers the ISY gateway on the local network and uses its address
+// The event stream is resubscribed at the discovered address.
+func (app *IsyApp) discoverGateway() error {
+	address, err := DiscoverIsy(SSDPAddress, DefaultDiscoveryTimeout)
+	if err != nil {
+		logrus.Warningf("IsyApp.discoverGateway: %s", err)
+		app.pub.UpdateNodeStatus(app.gatewayHWID(), map[types.NodeStatus]string{
+			types.NodeStatusRunState:  types.NodeRunStateError,
+			types.NodeStatusLastError: "No ISY gateway discovered on the local network",
+		})
+		return err
+	}
+	logrus.Infof("IsyApp.discoverGateway: Discovered ISY gateway at %s", address)
+	app.Stop()
+	app.isyAPI.address = address
+	app.readFailures = 0
+	app.discoveredMAC = ""
+	app.Start()
+	return nil
+}
 
+// Start subscribes to the ISY event stream
+// If the subscription fails then changes are only picked up by polling
+func (app *IsyApp) Start() {
+	// the gateway is not yet discovered
+	if app.isyAPI.address == "" {
+		return
+	}
+	eventStream, err := app.isyAPI.Subscribe(app.HandleIsyEvent)
+	if err != nil {
+		logrus.Warningf("IsyApp.Start: Unable to subscribe to ISY events on address %s: %v", app.isyAPI.address, err)
+		return
+	}
+	app.eventStream = eventStream
 }
 
-// NewIsyApp creates the app
+// Stop closes the subscription to the ISY event stream
+func (app *IsyApp) Stop() {
+	if app.eventStream != nil {
+		_ = app.eventStream.Close()
+		app.eventStream = nil
+	}
+}
+
+// NewIsyApp creates the app for the single gateway in the configuration
 // This creates a node for the gateway
 func NewIsyApp(config *IsyAppConfig, pub *publisher.Publisher) *IsyApp {
+	gateway := GatewayConfig{
+		GatewayAddress:  config.GatewayAddress,
+		LoginName:       config.LoginName,
+		Password:        config.Password,
+		PollIntervalSec: DefaultPollIntervalSec,
+		X10Units:        config.X10Units,
+		CaptureFolder:   config.CaptureFolder,
+		Simulation:      config.Simulation,
+	}
+	app := newIsyApp(config, gateway, pub)
+	pub.SetPollInterval(gateway.PollIntervalSec, app.Poll)
+	pub.SetNodeConfigHandler(app.HandleConfigCommand)
+	return app
+}
+
+// newIsyApp creates the app for a gateway
+// This creates a node for the gateway
+func newIsyApp(config *IsyAppConfig, gateway GatewayConfig, pub *publisher.Publisher) *IsyApp {
 	app := IsyApp{
-		config: config,
-		pub:    pub,
+		config:  config,
+		gateway: gateway,
+		pub:     pub,
 		// gatewayNodeAddr: nodes.MakeNodeDiscoveryAddress(pub.Zone, config.PublisherID, GatewayID),
-		isyAPI: NewIsyAPI(config.GatewayAddress, config.LoginName, config.Password),
+		isyAPI:         NewIsyAPI(gateway.GatewayAddress, gateway.LoginName, gateway.Password),
+		isyDevice:      &IsyDevice{},
+		controls:       NewControlRegistry(),
+		isyNodes:       make(map[string]*IsyNode),
+		sensorLastSeen: make(map[string]time.Time),
+		relayReleases:  make(map[string]*time.Timer),
+		nodeServers:    make(map[string]*nodeServer),
+		profileRetries: make(map[string]loadRetry),
+		zwaveKinds:     make(map[string]zwaveDevice),
+		lastQueryAll:   time.Now(),
+		logs:           NewIsyLogs(config.LogHistorySize),
 	}
 	if app.config.PublisherID == "" {
 		app.config.PublisherID = appID
 	}
-	pub.SetPollInterval(60, app.Poll)
-	pub.SetNodeConfigHandler(app.HandleConfigCommand)
+	app.isyAPI.SetCaptureFolder(gateway.CaptureFolder)
+	app.isyAPI.SetSimulationFaults(gateway.Simulation)
 	// // Discover the node(s) and outputs. Use default for republishing discovery
 	// isyPub.SetDiscoveryInterval(0, app.Discover)
 	app.SetupGatewayNode(pub)
@@ -132,9 +321,20 @@ func Run() {
 	appConfig := &IsyAppConfig{PublisherID: appID}
 	isyPub, _ := publisher.NewAppPublisher(appID, "", appConfig, "", true)
 
-	_ = NewIsyApp(appConfig, isyPub)
+	if len(appConfig.Gateways) > 0 {
+		gateways := NewIsyGateways(appConfig, isyPub)
+		isyPub.Start()
+		gateways.Start()
+		isyPub.WaitForSignal()
+		gateways.Stop()
+		isyPub.Stop()
+		return
+	}
+	app := NewIsyApp(appConfig, isyPub)
 
 	isyPub.Start()
+	app.Start()
 	isyPub.WaitForSignal()
+	app.Stop()
 	isyPub.Stop()
 }
@@ -1,7 +1,17 @@
 package internal_test
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -17,6 +27,11 @@ import (
 
 // Use simulation files
 const deckLightsID = "15 2D A 1"
+const keypadID = "1F 3A 7C 1"
+const motionSensorID = "2A 9B 4 1"
+const ioLincID = "1E 65 F2 1"
+const energyMeterID = "3C 11 8A 1"
+const loadControllerID = "3C 20 1 1"
 const appID = "isy99"
 
 // For testing, IsyAPI.isyRequest simulates reading isy from file using the path:
@@ -29,6 +44,47 @@ var testConfigFolder = "../test"
 var nodesFile = testConfigFolder + "/isy99-nodes.json"
 var messengerConfig = &messaging.MessengerConfig{Domain: "test"}
 
+// Run 'go test ./internal -run TestGoldenParsers -update' to update the golden files after a parser change
+var updateGolden = flag.Bool("update", false, "update the golden files of the XML parsers")
+
+// firmwareCorpus with the folders of gateway responses by platform and firmware version
+var firmwareCorpus = map[string]string{
+	"isy99-3.2.6":  testConfigFolder + "/rest",
+	"isy994-4.7.3": testConfigFolder + "/firmware/isy994-4.7.3/rest",
+	"isy994-5.3.0": testConfigFolder + "/firmware/isy994-5.3.0/rest",
+}
+
+// xmlParsers with the parser of each response file in the firmware corpus
+var xmlParsers = map[string]func(data []byte) (interface{}, error){
+	"config.xml":                   func(data []byte) (interface{}, error) { return internal.ParseIsyConfig(data) },
+	"nodes.xml":                    func(data []byte) (interface{}, error) { return internal.ParseIsyNodes(data) },
+	"status.xml":                   func(data []byte) (interface{}, error) { return internal.ParseIsyStatus(data) },
+	"status/15 2D A 1.xml":         func(data []byte) (interface{}, error) { return internal.ParseIsyNodeStatus(data) },
+	"nodes/2F 11 A3 1/get/OL.xml":  func(data []byte) (interface{}, error) { return internal.ParseIsyProp(data) },
+	"nodes/defs.xml":               func(data []byte) (interface{}, error) { return internal.ParseIsyNodeDefs(data) },
+	"profiles/ns/0/connection.xml": func(data []byte) (interface{}, error) { return internal.ParseIsyNodeServers(data) },
+	"profiles/family/10/profile/1/download/nodedef/nodedefs.xml": func(data []byte) (interface{}, error) {
+		return internal.ParseIsyNodeDefs(data)
+	},
+	"profiles/family/10/profile/1/download/nls/en_us.txt": func(data []byte) (interface{}, error) {
+		return internal.ParseIsyNLS(data)
+	},
+}
+
+// malformedXML with responses that each parser must reject
+var malformedXML = []string{
+	"",
+	"not xml",
+	"<html><body>401 Unauthorized</body></html>",
+	`<RestResponse succeeded="false"><status>404</status></RestResponse>`,
+	"<nodes><node><address>15 2D A 1</address>",
+	"<configuration><app_version>4.7.3</app_version>",
+	"<nodes><node><name>no address</name></node></nodes>",
+	`<nodes><node><property id="ST" value="0"/></node></nodes>`,
+	"<properties><property value=\"0\"/></properties>",
+	"<property value=\"0\"/>",
+}
+
 // Read ISY device and check if more than 1 node is returned. A minimum of 1 is expected if the device is online with
 // an additional node for each connected node.
 func TestReadIsyGateway(t *testing.T) {
@@ -41,6 +97,13 @@ func TestReadIsyGateway(t *testing.T) {
 	isyDevice, err := isyAPI.ReadIsyGateway()
 	assert.NoError(t, err)
 	assert.NotEmptyf(t, isyDevice.Configuration.AppVersion, "Expected an application version")
+	assert.True(t, len(isyDevice.Configuration.Features) > 0, "Expected gateway features")
+	assert.True(t, len(isyDevice.Configuration.Controls) > 0, "Expected gateway controls")
+	assert.False(t, isyDevice.IsInstalled(internal.FeatureNetworking))
+	subsystems := isyDevice.Subsystems()
+	assert.True(t, subsystems.Programs)
+	assert.True(t, subsystems.Elk)
+	assert.False(t, subsystems.Climate)
 
 	// use a simulation file
 	isyNodes, err := isyAPI.ReadIsyNodes()
@@ -52,6 +115,62 @@ func TestReadIsyGateway(t *testing.T) {
 	isyDevice, err = isyAPI.ReadIsyGateway()
 	assert.Error(t, err)
 }
+
+// Control definitions from the gateway configuration drive the outputs
+func TestControlRegistry(t *testing.T) {
+	isyAPI := internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
+	isyDevice, err := isyAPI.ReadIsyGateway()
+	require.NoError(t, err)
+	registry := internal.NewControlRegistry()
+	registry.Load(isyDevice.Configuration.Controls)
+
+	assert.Equal(t, "On Level", registry.GetControl("OL").Label)
+	assert.True(t, registry.HasInput("ST"), "Status has on/off commands")
+	assert.True(t, registry.HasInput("RR"), "Ramp rate is writable")
+	assert.False(t, registry.HasInput("CLIHUM"), "Humidity is read-only")
+	assert.Equal(t, types.OutputTypeDimmer, registry.OutputType("OL"))
+	assert.Equal(t, types.OutputType("rampRate"), registry.OutputType("RR"))
+	assert.Equal(t, types.OutputType("heatCoolState"), registry.OutputType("CLIHCS"))
+	// unknown controls are read-only
+	assert.False(t, registry.HasInput("NEWCTL"))
+	assert.Equal(t, types.NodeTypeUnknown, registry.NodeType("NEWCTL"))
+	// labels with non-ASCII letters or without letters
+	registry.Load([]internal.IsyControl{{Name: "GV1", Label: "température éxterieure"}, {Name: "GV2", Label: "%"}})
+	assert.Equal(t, types.OutputType("températureÉxterieure"), registry.OutputType("GV1"))
+	assert.Equal(t, types.OutputType("GV2"), registry.OutputType("GV2"))
+}
+
+// Raw ISY values are normalized using their unit of measure
+func TestConvertUOM(t *testing.T) {
+	value, unit, known := internal.ConvertUOM(internal.IsyProp{ID: "ST", Value: "255", UOM: "on/off"})
+	assert.True(t, known)
+	assert.Equal(t, "true", value)
+	assert.Empty(t, unit)
+
+	value, unit, _ = internal.ConvertUOM(internal.IsyProp{ID: "ST", Value: "255", UOM: "%/on/off"})
+	assert.Equal(t, "100", value)
+	assert.Equal(t, types.UnitPercent, unit)
+	value, _, _ = internal.ConvertUOM(internal.IsyProp{ID: "ST", Value: "128", UOM: "100"})
+	assert.Equal(t, "50", value)
+
+	// temperature with precision
+	value, unit, _ = internal.ConvertUOM(internal.IsyProp{ID: "CLITEMP", Value: "725", UOM: "17", Precision: "1"})
+	assert.Equal(t, "72.5", value)
+	assert.Equal(t, types.UnitFahrenheit, unit)
+
+	// unknown units are passed as is
+	value, _, known = internal.ConvertUOM(internal.IsyProp{ID: "XX", Value: "12", UOM: "999"})
+	assert.False(t, known)
+	assert.Equal(t, "12", value)
+	// input values are converted back to the unit of measure
+	raw, err := internal.ConvertToUOM("50", "100")
+	assert.NoError(t, err)
+	assert.Equal(t, "128", raw)
+	raw, err = internal.ConvertToUOM("21.5", "4")
+	assert.NoError(t, err)
+	assert.Equal(t, "21.5", raw)
+}
+
 func TestReadIsyStatus(t *testing.T) {
 	isyAPI := internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
 	// use a simulation file
@@ -93,6 +212,8 @@ func TestIsyAppGateway(t *testing.T) {
 	gwNodeID, err := app.ReadGateway()
 	assert.NoError(t, err)
 	assert.NotEmpty(t, gwNodeID)
+	assert.Equal(t, "elk,programs,variables", pub.GetNodeAttr(gwNodeID, internal.NodeAttrSubsystems))
+	assert.Equal(t, "ELK v2", pub.GetNodeAttr(gwNodeID, internal.NodeAttrSecuritySystem))
 
 	// error case - use real url
 	appConfig.GatewayAddress = "localhost"
@@ -188,6 +309,1024 @@ func TestSwitch(t *testing.T) {
 
 }
 
+// Status events keep the unit of measure of the polled property
+func TestDimmerEvent(t *testing.T) {
+	const dimmerID = "2F 11 A3 1"
+	os.Remove(nodesFile)
+	dimmerConfig := &internal.IsyAppConfig{GatewayAddress: "file://" + testConfigFolder + "/firmware/isy994-4.7.3"}
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, dimmerConfig, "", false)
+	assert.NoError(t, err)
+	app := internal.NewIsyApp(dimmerConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+	require.NotNil(t, pub.GetOutputByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance))
+
+	// events of older firmware only have the value
+	app.HandleIsyEvent(&internal.IsyEvent{Control: "ST", Action: "255", Node: dimmerID})
+	app.Poll(pub)
+	assert.Nil(t, pub.GetOutputByNodeHWID(dimmerID, types.OutputTypeOnOffSwitch, types.DefaultOutputInstance))
+	assert.Nil(t, pub.GetOutputByNodeHWID(dimmerID, types.OutputTypeSwitch, types.DefaultOutputInstance))
+	dimmerValue := pub.GetOutputValueByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance)
+	require.NotNil(t, dimmerValue)
+	assert.NotEqual(t, "true", dimmerValue.Value)
+
+	// the event stream reports the unit of measure and precision of the action
+	event := internal.IsyEvent{}
+	err = xml.Unmarshal([]byte(`<Event seqnum="3" sid="uuid:41"><control>ST</control>`+
+		`<action uom="100" prec="0">128</action><node>`+dimmerID+`</node><eventInfo></eventInfo></Event>`), &event)
+	require.NoError(t, err)
+	assert.Equal(t, "128", event.Action)
+	assert.Equal(t, "100", event.UOM)
+	assert.Equal(t, "0", event.Precision)
+	pub.Stop()
+}
+
+// Keypad buttons are published as outputs of the primary keypad node
+func TestKeypad(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	keypad := pub.GetNodeByHWID(keypadID)
+	require.NotNilf(t, keypad, "Keypad %s not found", keypadID)
+	buttonNode := pub.GetNodeByHWID("1F 3A 7C 3")
+	assert.Nil(t, buttonNode, "Keypad button should not be a separate node")
+
+	ledValue := pub.GetOutputValueByNodeHWID(keypadID, internal.OutputTypeLED, "3")
+	require.NotNil(t, ledValue, "LED output of button 3 not found")
+	assert.Equal(t, "true", ledValue.Value)
+
+	// button presses from the event stream are published on the pushbutton output
+	app.HandleIsyEvent(&internal.IsyEvent{Control: "DFON", Action: "255", Node: "1F 3A 7C 5"})
+	buttonValue := pub.GetOutputValueByNodeHWID(keypadID, types.OutputTypePushButton, "5")
+	require.NotNil(t, buttonValue, "Pushbutton output of button 5 not found")
+	assert.Equal(t, "DFON", buttonValue.Value)
+
+	// simulated event stream from test/rest/subscribe.xml
+	app.Start()
+	time.Sleep(time.Second)
+	buttonValue = pub.GetOutputValueByNodeHWID(keypadID, types.OutputTypePushButton, "4")
+	require.NotNil(t, buttonValue, "Pushbutton output of button 4 not found")
+	assert.Equal(t, "DON", buttonValue.Value)
+	ledValue = pub.GetOutputValueByNodeHWID(keypadID, internal.OutputTypeLED, "4")
+	assert.Equal(t, "true", ledValue.Value)
+	app.Stop()
+
+	pub.Stop()
+}
+
+// Sensor sub-nodes are published as read-only outputs of the primary sensor node
+func TestSensor(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	sensor := pub.GetNodeByHWID(motionSensorID)
+	require.NotNilf(t, sensor, "Motion sensor %s not found", motionSensorID)
+	assert.Nil(t, pub.GetNodeByHWID("2A 9B 4 2"), "Sensor sub-node should not be a separate node")
+	assert.Nil(t, pub.GetInputByNodeHWID(motionSensorID, types.InputTypeSwitch, types.DefaultInputInstance))
+
+	motion := pub.GetOutputValueByNodeHWID(motionSensorID, types.OutputTypeMotion, types.DefaultOutputInstance)
+	require.NotNil(t, motion)
+	assert.Equal(t, "false", motion.Value)
+	assert.Nil(t, pub.GetOutputByNodeHWID(motionSensorID, types.OutputTypeLuminance, types.DefaultOutputInstance))
+	dark := pub.GetOutputValueByNodeHWID(motionSensorID, internal.OutputTypeDark, types.DefaultOutputInstance)
+	require.NotNil(t, dark)
+	assert.Equal(t, "true", dark.Value)
+	// battery status has not been reported
+	battery := pub.GetOutputValueByNodeHWID(motionSensorID, internal.OutputTypeLowBattery, types.DefaultOutputInstance)
+	assert.Nil(t, battery)
+
+	app.HandleIsyEvent(&internal.IsyEvent{Control: "ST", Action: "255", Node: motionSensorID})
+	motion = pub.GetOutputValueByNodeHWID(motionSensorID, types.OutputTypeMotion, types.DefaultOutputInstance)
+	assert.Equal(t, "true", motion.Value)
+
+	app.CheckSensorHeartbeats()
+	runState, _ := pub.GetNodeStatus(motionSensorID, types.NodeStatusRunState)
+	assert.Equal(t, types.NodeRunStateReady, runState)
+
+	// a restarted service starts the heartbeat clock of sensors that already have a node
+	restarted := internal.NewIsyApp(appConfig, pub)
+	restarted.Poll(pub)
+	restarted.CheckSensorHeartbeats()
+	age, found := pub.GetNodeStatus(motionSensorID, internal.NodeStatusHeartbeatAge)
+	assert.True(t, found)
+	assert.Equal(t, "0", age)
+	pub.Stop()
+}
+
+// The I/O Linc relay and sensor are published as a single node
+func TestIoLinc(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	require.NotNil(t, pub.GetNodeByHWID(ioLincID))
+	assert.Nil(t, pub.GetNodeByHWID("1E 65 F2 2"), "I/O Linc relay should not be a separate node")
+	contact := pub.GetOutputValueByNodeHWID(ioLincID, types.OutputTypeContact, types.DefaultOutputInstance)
+	require.NotNil(t, contact)
+	assert.Equal(t, "false", contact.Value)
+	assert.Nil(t, pub.GetInputByNodeHWID(ioLincID, types.InputTypeContact, types.DefaultInputInstance))
+
+	relayInput := pub.GetInputByNodeHWID(ioLincID, types.InputTypeRelay, types.DefaultInputInstance)
+	require.NotNil(t, relayInput)
+	pub.PublishSetInput(relayInput.Address, "true")
+	time.Sleep(2 * time.Second)
+	relay := pub.GetOutputValueByNodeHWID(ioLincID, types.OutputTypeRelay, types.DefaultOutputInstance)
+	require.NotNil(t, relay)
+	assert.Equal(t, "true", relay.Value)
+
+	// in momentary mode the sensor closes the relay briefly when it turns on
+	ioLincAddr := nodes.MakeNodeConfigureAddress(pub.Domain(), pub.PublisherID(), ioLincID)
+	pub.PublishNodeConfigure(ioLincAddr, types.NodeAttrMap{
+		internal.NodeAttrMomentaryMode:       internal.MomentaryModeMomentary,
+		internal.NodeAttrMomentaryTime:       "1",
+		internal.NodeAttrSensorTriggersRelay: "true",
+	})
+	time.Sleep(time.Second)
+	pub.PublishSetInput(relayInput.Address, "false")
+	time.Sleep(time.Second)
+	app.HandleIsyEvent(&internal.IsyEvent{Control: "ST", Action: "255", Node: ioLincID})
+	app.Poll(pub)
+	relay = pub.GetOutputValueByNodeHWID(ioLincID, types.OutputTypeRelay, types.DefaultOutputInstance)
+	assert.Equal(t, "true", relay.Value)
+	time.Sleep(2 * time.Second)
+	app.Poll(pub)
+	relay = pub.GetOutputValueByNodeHWID(ioLincID, types.OutputTypeRelay, types.DefaultOutputInstance)
+	assert.Equal(t, "false", relay.Value, "Momentary relay was not released")
+
+	pub.Stop()
+}
+
+// X10 units from the configuration are controlled through the ISY
+func TestX10(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	// test/isy99.yaml contains X10 unit A1
+	x10Node := pub.GetNodeByHWID("X10-A1")
+	require.NotNil(t, x10Node, "X10 unit A1 not found")
+	switchInput := pub.GetInputByNodeHWID(x10Node.HWID, types.InputTypeSwitch, types.DefaultInputInstance)
+	require.NotNil(t, switchInput)
+	dimInput := pub.GetInputByNodeHWID(x10Node.HWID, types.InputTypePushButton, internal.X10InstanceDim)
+	require.NotNil(t, dimInput)
+
+	pub.PublishSetInput(switchInput.Address, "true")
+	time.Sleep(time.Second)
+	outputValue := pub.GetOutputValueByNodeHWID(x10Node.HWID, types.OutputTypeSwitch, types.DefaultOutputInstance)
+	require.NotNil(t, outputValue)
+	assert.Equal(t, "true", outputValue.Value)
+
+	// X10 traffic on the event stream
+	event := &internal.IsyEvent{Control: "_1", Action: "8"}
+	event.EventInfo.Content = "A1 " + internal.X10CmdOff
+	app.HandleIsyEvent(event)
+	outputValue = pub.GetOutputValueByNodeHWID(x10Node.HWID, types.OutputTypeSwitch, types.DefaultOutputInstance)
+	assert.Equal(t, "false", outputValue.Value)
+
+	pub.Stop()
+}
+
+// Elk areas and zones are published when the gateway has the Elk module
+func TestElk(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	armedState := pub.GetOutputValueByNodeHWID("elk-area-1", internal.OutputTypeArmedState, types.DefaultOutputInstance)
+	require.NotNil(t, armedState, "Elk area 1 not found")
+	assert.Equal(t, "disarmed", armedState.Value)
+	zoneStatus := pub.GetOutputValueByNodeHWID("elk-zone-2", internal.OutputTypeZoneStatus, types.DefaultOutputInstance)
+	require.NotNil(t, zoneStatus, "Elk zone 2 not found")
+	assert.Equal(t, "violated", zoneStatus.Value)
+	assert.Equal(t, "A10", pub.GetNodeAttr(deckLightsID, internal.NodeAttrElkID))
+
+	// area armed from the event stream
+	event := &internal.IsyEvent{Control: "_19", Action: "2"}
+	event.EventInfo.Content = `<ae type="3" area="1" val="1"/>`
+	app.HandleIsyEvent(event)
+	armedState = pub.GetOutputValueByNodeHWID("elk-area-1", internal.OutputTypeArmedState, types.DefaultOutputInstance)
+	assert.Equal(t, "armedAway", armedState.Value)
+
+	// the user code is not disclosed in errors
+	isyAPI := internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
+	isyAPI.SetSimulationFaults(internal.SimulationFaults{Offline: true})
+	err = isyAPI.WriteElkArm("1", "away", "4321")
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "4321")
+
+	pub.Stop()
+}
+
+// The device link table is compared with the ISY record of it
+func TestLinks(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	isyAPI := internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
+	deviceLinks, err := isyAPI.ReadDeviceLinks(deckLightsID)
+	require.NoError(t, err)
+	isyLinks, err := isyAPI.ReadIsyLinks(deckLightsID)
+	require.NoError(t, err)
+	assert.True(t, deviceLinks.Records[0].IsController())
+	assert.False(t, deviceLinks.Records[1].IsController())
+	compared := internal.CompareLinks(deviceLinks, isyLinks)
+	assert.Equal(t, internal.LinkStatusIdentical, compared["4087"])
+	assert.Equal(t, internal.LinkStatusDifferent, compared["4071"])
+	assert.Equal(t, internal.LinkStatusExtra, compared["4063"])
+	assert.Equal(t, internal.LinkStatusMissing, compared["4055"])
+	// the device and the ISY can report an address with a different number of digits
+	compared = internal.CompareLinks(
+		&internal.IsyLinkTable{Records: []internal.IsyLinkRecord{{MemAddress: "FF8", Flags: "A2"}}},
+		&internal.IsyLinkTable{Records: []internal.IsyLinkRecord{{MemAddress: "0FF8", Flags: "A2"}}})
+	assert.Equal(t, map[string]string{"0FF8": internal.LinkStatusIdentical}, compared)
+
+	readLinksInput := pub.GetInputByNodeHWID(types.NodeIDGateway, internal.InputTypeReadLinks, types.DefaultInputInstance)
+	require.NotNil(t, readLinksInput)
+	err = app.HandleReadLinks(readLinksInput, deckLightsID)
+	assert.NoError(t, err)
+	assert.Equal(t, "3", pub.GetNodeAttr(deckLightsID, internal.NodeAttrLinkMismatches))
+	assert.Contains(t, pub.GetNodeAttr(deckLightsID, internal.NodeAttrLinkTable), `"status":"missing"`)
+
+	// devices without a link table fail
+	err = app.ReadLinks(keypadID)
+	assert.Error(t, err)
+
+	pub.Stop()
+}
+
+// Devices can be queried individually or all at once through the gateway
+func TestQuery(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	queryInput := pub.GetInputByNodeHWID(deckLightsID, internal.InputTypeQuery, types.DefaultInputInstance)
+	require.NotNil(t, queryInput, "Missing query input on deck lights")
+	assert.Nil(t, pub.GetInputByNodeHWID(motionSensorID, internal.InputTypeQuery, types.DefaultInputInstance))
+	err = app.HandleQuery(queryInput, "")
+	assert.NoError(t, err)
+
+	gwQueryInput := pub.GetInputByNodeHWID(types.NodeIDGateway, internal.InputTypeQuery, types.DefaultInputInstance)
+	require.NotNil(t, gwQueryInput)
+	err = app.HandleQuery(gwQueryInput, deckLightsID)
+	assert.NoError(t, err)
+	err = app.HandleQuery(gwQueryInput, "")
+	assert.NoError(t, err)
+	// the status of this node is not in the simulation files
+	err = app.QueryNode(keypadID)
+	assert.Error(t, err)
+
+	pub.Stop()
+}
+
+// Energy meter properties are published with their units
+func TestEnergyMeter(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	power := pub.GetOutputValueByNodeHWID(energyMeterID, types.OutputTypeElectricPower, types.DefaultOutputInstance)
+	require.NotNil(t, power, "Missing power output of energy meter")
+	assert.Equal(t, "125", power.Value)
+	energy := pub.GetOutputByNodeHWID(energyMeterID, types.OutputTypeElectricEnergy, types.DefaultOutputInstance)
+	require.NotNil(t, energy, "Missing energy output of energy meter")
+	assert.Equal(t, types.UnitKWH, energy.Unit)
+	energyValue := pub.GetOutputValueByNodeHWID(energyMeterID, types.OutputTypeElectricEnergy, types.DefaultOutputInstance)
+	assert.Equal(t, "45.32", energyValue.Value)
+	voltage := pub.GetOutputValueByNodeHWID(energyMeterID, types.OutputTypeVoltage, types.DefaultOutputInstance)
+	require.NotNil(t, voltage)
+	assert.Equal(t, "119.8", voltage.Value)
+
+	resetInput := pub.GetInputByNodeHWID(energyMeterID, internal.InputTypeResetEnergy, types.DefaultInputInstance)
+	require.NotNil(t, resetInput, "Missing reset input of energy meter")
+	err = app.ResetEnergy(resetInput)
+	assert.NoError(t, err)
+
+	// load controllers are in the energy category but are switches
+	assert.Nil(t, pub.GetOutputByNodeHWID(loadControllerID, types.OutputTypeElectricPower, types.DefaultOutputInstance))
+	assert.NotNil(t, pub.GetOutputByNodeHWID(loadControllerID, types.OutputTypeSwitch, types.DefaultOutputInstance))
+
+	pub.Stop()
+}
+
+// Climate module data is published on the climate node
+func TestClimate(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	// the simulated gateway doesn't have the module installed
+	app.UpdateClimate()
+
+	temperature := pub.GetOutputByNodeHWID(internal.ClimateNodeHWID, types.OutputTypeTemperature, types.DefaultOutputInstance)
+	require.NotNil(t, temperature, "Missing climate temperature")
+	assert.Equal(t, types.UnitFahrenheit, temperature.Unit)
+	eto := pub.GetOutputValueByNodeHWID(internal.ClimateNodeHWID, internal.OutputTypeEvapotranspiration, types.DefaultOutputInstance)
+	require.NotNil(t, eto, "Missing evapotranspiration")
+	assert.Equal(t, "0.18", eto.Value)
+	rain := pub.GetOutputValueByNodeHWID(internal.ClimateNodeHWID, internal.OutputTypeRain, types.DefaultOutputInstance)
+	require.NotNil(t, rain)
+	assert.Equal(t, "0.12", rain.Value)
+
+	value, unit := internal.ParseClimateValue("5 mph")
+	assert.Equal(t, "5", value)
+	assert.Equal(t, types.UnitMph, unit)
+
+	pub.Stop()
+}
+
+// Network resources are published as nodes with a trigger input
+func TestNetResources(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	// the simulated gateway doesn't have the networking module installed
+	app.UpdateNetResources()
+
+	resourceNode := pub.GetNodeByHWID("resource-2")
+	require.NotNil(t, resourceNode, "Missing network resource node")
+	name, _ := pub.GetNodeConfigString(resourceNode.HWID, types.NodeAttrName, "")
+	assert.Equal(t, "Doorbell camera snapshot", name)
+	triggerInput := pub.GetInputByNodeHWID(resourceNode.HWID, types.InputTypePushButton, types.DefaultInputInstance)
+	require.NotNil(t, triggerInput, "Missing trigger input")
+	err = app.TriggerNetResource(triggerInput)
+	assert.NoError(t, err)
+
+	pub.Stop()
+}
+
+// The gateway clock and system status are published as gateway outputs
+func TestGatewayStatus(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	clock := pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeClock, types.DefaultOutputInstance)
+	require.NotNil(t, clock, "Missing gateway clock")
+	assert.Equal(t, "2020-10-08T11:56:54Z", clock.Value)
+	sunrise := pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeSunrise, types.DefaultOutputInstance)
+	require.NotNil(t, sunrise)
+	assert.Equal(t, "2020-10-08T14:20:00Z", sunrise.Value)
+	// the simulated gateway started 114 seconds before its clock time
+	uptime := pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeUptime, types.DefaultOutputInstance)
+	require.NotNil(t, uptime, "Missing gateway uptime")
+	assert.Equal(t, "114", uptime.Value)
+	// no event reported the system status yet
+	systemStatus := pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeSystemStatus, types.DefaultOutputInstance)
+	require.NotNil(t, systemStatus)
+	assert.Equal(t, "ready", systemStatus.Value)
+	// the simulated clock is far behind
+	lastError, _ := pub.GetNodeStatus(types.NodeIDGateway, types.NodeStatusLastError)
+	assert.Contains(t, lastError, "clock drifts")
+	driftAlert := pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeClockDriftAlert, types.DefaultOutputInstance)
+	require.NotNil(t, driftAlert)
+	assert.Equal(t, "true", driftAlert.Value)
+	app.Poll(pub)
+	lastError, _ = pub.GetNodeStatus(types.NodeIDGateway, types.NodeStatusLastError)
+	assert.Contains(t, lastError, "clock drifts", "A successful poll replaced the clock drift error")
+	// the error is cleared when the drift is within the threshold
+	driftSec := appConfig.ClockDriftSec
+	appConfig.ClockDriftSec = 100 * 365 * 24 * 3600
+	app.UpdateGatewayStatus()
+	appConfig.ClockDriftSec = driftSec
+	lastError, _ = pub.GetNodeStatus(types.NodeIDGateway, types.NodeStatusLastError)
+	assert.Empty(t, lastError)
+	driftAlert = pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeClockDriftAlert, types.DefaultOutputInstance)
+	assert.Equal(t, "false", driftAlert.Value)
+
+	event := &internal.IsyEvent{Control: "_5", Action: "1"}
+	app.HandleIsyEvent(event)
+	systemStatus = pub.GetOutputValueByNodeHWID(types.NodeIDGateway, internal.OutputTypeSystemStatus, types.DefaultOutputInstance)
+	require.NotNil(t, systemStatus)
+	assert.Equal(t, "busy", systemStatus.Value)
+
+	pub.Stop()
+}
+
+// The ISY logs are read incrementally
+func TestLogs(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+	errorLog := app.RecentLog(internal.OutputTypeErrorLog)
+	require.Equal(t, 4, len(errorLog))
+	assert.Equal(t, "-200000", errorLog[3].Fields[2])
+	assert.Equal(t, 2, len(app.RecentLog(internal.OutputTypeEventLog)))
+	// nothing new in the simulation log
+	app.UpdateLogs()
+	assert.Equal(t, 4, len(app.RecentLog(internal.OutputTypeErrorLog)))
+	startTime := internal.StartTime(errorLog, time.UTC)
+	assert.Equal(t, "2020-10-08T04:55:00Z", startTime.Format(time.RFC3339))
+
+	entries := internal.ParseIsyLog("line1\tA\nline2\tB\r\n\nline3\tC\n")
+	require.Equal(t, 3, len(entries))
+	newEntries := internal.NewLogEntries(entries, "line2\tB")
+	require.Equal(t, 1, len(newEntries))
+	assert.Equal(t, "line3\tC", newEntries[0].Line)
+	// the log was cleared
+	assert.Equal(t, 3, len(internal.NewLogEntries(entries, "line0")))
+
+	pub.Stop()
+}
+
+// Multiple gateways each have their own gateway node and node namespace
+func TestMultipleGateways(t *testing.T) {
+	os.Remove(nodesFile)
+	multiConfig := &internal.IsyAppConfig{Gateways: []internal.GatewayConfig{
+		{ID: "building1", GatewayAddress: "file://../test"},
+		{ID: "building2", GatewayAddress: "file://../test"},
+		{ID: "building3", GatewayAddress: "localhost"},
+		{ID: "building4"},
+		{ID: "building5"},
+	}}
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, multiConfig, "", false)
+	assert.NoError(t, err)
+
+	// only one gateway is discovered
+	gateways := internal.NewIsyGateways(multiConfig, pub)
+	require.Equal(t, 4, len(gateways.Apps()))
+	multiConfig.Gateways = multiConfig.Gateways[:3]
+	gateways = internal.NewIsyGateways(multiConfig, pub)
+	require.Equal(t, 3, len(gateways.Apps()))
+	pub.Start()
+	for _, app := range gateways.Apps() {
+		app.Poll(pub)
+	}
+	assert.NotNil(t, pub.GetNodeByHWID("building1"), "Missing gateway node of building1")
+	assert.NotNil(t, pub.GetNodeByHWID("building1:"+deckLightsID))
+	assert.NotNil(t, pub.GetNodeByHWID("building2:"+deckLightsID))
+	assert.Nil(t, pub.GetNodeByHWID(deckLightsID))
+	assert.NotNil(t, pub.GetNodeByHWID("building2:elk-area-1"))
+
+	// gateways fail independently
+	runState, _ := pub.GetNodeStatus("building3", types.NodeStatusRunState)
+	assert.Equal(t, types.NodeRunStateError, runState)
+	runState, _ = pub.GetNodeStatus("building1", types.NodeStatusRunState)
+	assert.Equal(t, types.NodeRunStateReady, runState)
+
+	// inputs use the ISY address of the node
+	switchInput := pub.GetInputByNodeHWID("building1:"+deckLightsID, types.InputTypeSwitch, types.DefaultInputInstance)
+	require.NotNil(t, switchInput)
+	err = gateways.Apps()[0].SwitchOnOff(switchInput, "on")
+	assert.NoError(t, err)
+
+	// configuration is handled by the gateway of the node
+	gateways.HandleConfigCommand("building2:"+deckLightsID, types.NodeAttrMap{types.NodeAttrName: "Patio lights"})
+	name, _ := pub.GetNodeConfigValue("building2:"+deckLightsID, types.NodeAttrName)
+	assert.Equal(t, "Patio lights", name)
+
+	pub.Stop()
+}
+
+// The ISY is discovered using SSDP
+func TestDiscoverIsy(t *testing.T) {
+	responder, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	defer responder.Close()
+	go func() {
+		buffer := make([]byte, 2048)
+		n, remoteAddr, err := responder.ReadFrom(buffer)
+		if err != nil || !strings.HasPrefix(string(buffer[:n]), "M-SEARCH") {
+			return
+		}
+		// another device responds first
+		_, _ = responder.WriteTo([]byte("HTTP/1.1 200 OK\r\n"+
+			"ST: urn:schemas-upnp-org:device:MediaRenderer:1\r\n"+
+			"LOCATION: http://10.3.3.40:8080/desc.xml\r\n\r\n"), remoteAddr)
+		_, _ = responder.WriteTo([]byte("HTTP/1.1 200 OK\r\n"+
+			"CACHE-CONTROL: max-age=1800\r\n"+
+			"LOCATION: http://10.3.3.31/desc\r\n"+
+			"ST: urn:udi-com:device:X_Insteon_Lighting_Device:1\r\n"+
+			"USN: uuid:00:21:b9:01:0e:7b::urn:udi-com:device:X_Insteon_Lighting_Device:1\r\n\r\n"), remoteAddr)
+	}()
+
+	address, err := internal.DiscoverIsy(responder.LocalAddr().String(), 2*time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "10.3.3.31", address)
+
+	// nobody responds
+	_, err = internal.DiscoverIsy(responder.LocalAddr().String(), 500*time.Millisecond)
+	assert.Error(t, err)
+}
+
+// The event stream is resubscribed when the ISY drops the connection
+func TestSubscribe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	const response = "<s:Envelope><s:Body><SubscriptionResponse><SID>uuid:41</SID>" +
+		"<duration>0</duration></SubscriptionResponse></s:Body></s:Envelope>"
+	// the ISY accepts the first two subscriptions and drops the first after one event
+	go func() {
+		for seqNum := 1; ; seqNum++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_, _ = conn.Read(make([]byte, 4096))
+			_, _ = conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: " +
+				strconv.Itoa(len(response)) + "\r\n\r\n" + response +
+				"<Event seqnum=\"" + strconv.Itoa(seqNum) + "\" sid=\"uuid:41\"><control>ST</control>" +
+				"<action>255</action><node>" + deckLightsID + "</node><eventInfo></eventInfo></Event>"))
+			if seqNum == 1 {
+				_ = conn.Close()
+			}
+		}
+	}()
+	events := make(chan *internal.IsyEvent, 2)
+	isyAPI := internal.NewIsyAPI(listener.Addr().String(), appConfig.LoginName, appConfig.Password)
+	stream, err := isyAPI.Subscribe(func(event *internal.IsyEvent) { events <- event })
+	require.NoError(t, err)
+	defer stream.Close()
+	for _, seqNum := range []string{"1", "2"} {
+		select {
+		case event := <-events:
+			assert.Equal(t, seqNum, event.SeqNum)
+			assert.Equal(t, deckLightsID, event.Node)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No event %s received", seqNum)
+		}
+	}
+
+	// a rejected subscription fails
+	rejecter := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer rejecter.Close()
+	isyAPI = internal.NewIsyAPI(strings.TrimPrefix(rejecter.URL, "http://"), appConfig.LoginName, "wrong")
+	_, err = isyAPI.Subscribe(func(event *internal.IsyEvent) {})
+	assert.Error(t, err)
+}
+
+// Scenes, programs and variables are read from the gateway
+func TestProgramsAndVariables(t *testing.T) {
+	isyAPI := internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
+	isyNodes, err := isyAPI.ReadIsyNodes()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(isyNodes.Groups))
+	assert.False(t, isyNodes.Groups[0].IsScene(), "The root group is not a scene")
+	assert.True(t, isyNodes.Groups[1].IsScene())
+	assert.Equal(t, 2, len(isyNodes.Groups[1].Members))
+	assert.Equal(t, 2, len(isyNodes.Folders))
+
+	programs, err := isyAPI.ReadPrograms()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(programs.Programs))
+	assert.True(t, programs.Programs[0].Folder)
+	assert.Equal(t, "Porch light at sunset", programs.Programs[1].Name)
+	assert.False(t, programs.Programs[2].Enabled)
+	err = isyAPI.WriteProgramCommand("0002", internal.ProgramCmdRun)
+	assert.NoError(t, err)
+	assert.True(t, internal.IsProgramCommand(internal.ProgramCmdRunThen))
+	assert.False(t, internal.IsProgramCommand("runthen"))
+
+	variables, err := isyAPI.ReadVariables(internal.VarTypeInteger)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(variables.Variables))
+	assert.Equal(t, "3", variables.Variables[0].Value)
+	definitions, err := isyAPI.ReadVariableDefinitions(internal.VarTypeState)
+	require.NoError(t, err)
+	assert.Equal(t, "guestMode", definitions.Names()["1"])
+
+	raw, err := isyAPI.ReadRaw("/rest/config")
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), "<configuration>")
+}
+
+// Requests to a gateway are captured as simulation files that reproduce the gateway offline
+func TestCapture(t *testing.T) {
+	const login = "admin"
+	const password = "secret99"
+	const elkCode = "4321"
+	// the gateway serves the simulation files and accepts commands
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/cmd/") {
+			_, _ = w.Write([]byte("<RestResponse succeeded=\"true\"><status>200</status></RestResponse>"))
+			return
+		}
+		http.ServeFile(w, r, testConfigFolder+r.URL.Path+".xml")
+	}))
+	defer gateway.Close()
+	captureFolder, err := ioutil.TempDir("", "isy99-capture")
+	require.NoError(t, err)
+	defer os.RemoveAll(captureFolder)
+
+	isyAPI := internal.NewIsyAPI(strings.TrimPrefix(gateway.URL, "http://"), login, password)
+	isyAPI.SetCaptureFolder(captureFolder)
+	isyNodes, err := isyAPI.ReadIsyNodes()
+	require.NoError(t, err)
+	err = isyAPI.WriteOnOff(deckLightsID, true)
+	require.NoError(t, err)
+	err = isyAPI.WriteElkArm("1", "away", elkCode)
+	require.NoError(t, err)
+
+	// responses are captured as is
+	original, err := ioutil.ReadFile(testConfigFolder + "/rest/nodes.xml")
+	require.NoError(t, err)
+	captured, err := ioutil.ReadFile(captureFolder + "/rest/nodes.xml")
+	require.NoError(t, err)
+	assert.Equal(t, original, captured)
+	// the query with the Elk code is not part of the file name
+	_, err = os.Stat(captureFolder + "/rest/elk/area/1/cmd/arm.xml")
+	assert.NoError(t, err)
+
+	// replay the capture
+	replayAPI := internal.NewIsyAPI("file://"+captureFolder, "", "")
+	replayNodes, err := replayAPI.ReadIsyNodes()
+	require.NoError(t, err)
+	assert.Equal(t, len(isyNodes.Nodes), len(replayNodes.Nodes))
+	command, err := replayAPI.ReadRaw("/rest/nodes/" + deckLightsID + "/cmd/DON")
+	require.NoError(t, err)
+	assert.Contains(t, string(command), "succeeded")
+	_, err = replayAPI.ReadIsyStatus()
+	assert.Error(t, err, "Expected only requested paths to be captured")
+}
+
+// Faults injected in simulation mode drive the gateway error states
+func TestSimulationFaults(t *testing.T) {
+	os.Remove(nodesFile)
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(appConfig, pub)
+	pub.Start()
+	gwNodeID, err := app.ReadGateway()
+	require.NoError(t, err)
+	runState, _ := pub.GetNodeStatus(gwNodeID, types.NodeStatusRunState)
+	assert.Equal(t, types.NodeRunStateReady, runState)
+
+	app.SetSimulationFaults(internal.SimulationFaults{Offline: true})
+	_, err = app.ReadGateway()
+	assert.Error(t, err)
+	runState, _ = pub.GetNodeStatus(gwNodeID, types.NodeStatusRunState)
+	assert.Equal(t, types.NodeRunStateError, runState)
+	app.SetSimulationFaults(internal.SimulationFaults{Unauthorized: true})
+	_, err = app.ReadGateway()
+	assert.Error(t, err)
+
+	app.SetSimulationFaults(internal.SimulationFaults{LatencyMs: 20})
+	_, err = app.ReadGateway()
+	assert.NoError(t, err)
+	runState, _ = pub.GetNodeStatus(gwNodeID, types.NodeStatusRunState)
+	assert.Equal(t, types.NodeRunStateReady, runState)
+	latency, _ := pub.GetNodeStatus(gwNodeID, types.NodeStatusLatencyMSec)
+	assert.NotEqual(t, "0", latency)
+	pub.Stop()
+
+	isyAPI := internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
+	isyAPI.SetSimulationFaults(internal.SimulationFaults{MalformedXML: []string{"/rest/status"}})
+	_, err = isyAPI.ReadIsyStatus()
+	assert.Error(t, err)
+	_, err = isyAPI.ReadIsyNodes()
+	assert.NoError(t, err)
+
+	isyAPI.SetSimulationFaults(internal.SimulationFaults{Unresponsive: []string{deckLightsID}})
+	assert.Error(t, isyAPI.WriteOnOff(deckLightsID, true))
+	assert.Error(t, isyAPI.Query(deckLightsID))
+	assert.NoError(t, isyAPI.WriteOnOff(keypadID, true))
+	isyAPI.SetSimulationFaults(internal.SimulationFaults{DropCommands: true})
+	assert.NoError(t, isyAPI.WriteOnOff(deckLightsID, true))
+
+	// random errors repeat with the same seed
+	failures := func() (result []bool) {
+		isyAPI.SetSimulationFaults(internal.SimulationFaults{ErrorRate: 0.5, Seed: 42})
+		for i := 0; i < 20; i++ {
+			_, err := isyAPI.ReadIsyGateway()
+			result = append(result, err != nil)
+		}
+		return result
+	}
+	firstRun := failures()
+	assert.Contains(t, firstRun, true)
+	assert.Contains(t, firstRun, false)
+	assert.Equal(t, firstRun, failures())
+}
+
+// Firmware 5.x reports node families and all node properties
+func TestFirmware5(t *testing.T) {
+	assert.True(t, internal.ParseFirmwareVersion("5.0.16C").AtLeast(5, 0))
+	assert.False(t, internal.ParseFirmwareVersion("4.7.3").AtLeast(5, 0))
+	assert.Equal(t, "0.0.0", internal.ParseFirmwareVersion("unknown").String())
+
+	isyAPI := internal.NewIsyAPI("file://"+firmwareCorpus["isy994-5.3.0"]+"/..", "", "")
+	isyDevice, err := isyAPI.ReadIsyGateway()
+	require.NoError(t, err)
+	assert.Equal(t, internal.IsyFirmware{Major: 5, Minor: 3}, isyDevice.Firmware())
+	assert.Equal(t, isyDevice.Firmware(), isyAPI.Firmware())
+
+	isyNodes, err := isyAPI.ReadIsyNodes()
+	require.NoError(t, err)
+	require.Equal(t, 5, len(isyNodes.Nodes))
+	assert.Equal(t, internal.FamilyInsteon, isyNodes.Nodes[0].FamilyID())
+	assert.Equal(t, internal.FamilyZWave, isyNodes.Nodes[1].FamilyID())
+	assert.Equal(t, "ZY002_153", isyNodes.Nodes[1].NodeDefID)
+	assert.Equal(t, 0x40, isyNodes.Nodes[1].ZWaveGenericClass())
+	assert.Equal(t, 0, isyNodes.Nodes[0].ZWaveGenericClass())
+	assert.Equal(t, "1", isyNodes.Nodes[2].Family.Instance)
+
+	isyStatus, err := isyAPI.ReadIsyStatus()
+	require.NoError(t, err)
+	require.Equal(t, 3, len(isyStatus.Nodes[0].Props))
+	assert.Equal(t, "ST", isyStatus.Nodes[0].Prop.ID)
+	assert.Equal(t, "191", isyStatus.Nodes[0].Prop.Value)
+
+	// firmware 5.x reads a single property with the get command
+	prop, err := isyAPI.ReadIsyProperty("2F 11 A3 1", "OL")
+	require.NoError(t, err)
+	assert.Equal(t, "255", prop.Value)
+
+	// older firmware reads the property from the node status
+	isyAPI = internal.NewIsyAPI(appConfig.GatewayAddress, appConfig.LoginName, appConfig.Password)
+	_, err = isyAPI.ReadIsyGateway()
+	require.NoError(t, err)
+	assert.False(t, isyAPI.Firmware().AtLeast(4, 0))
+	prop, err = isyAPI.ReadIsyProperty(deckLightsID, "ST")
+	require.NoError(t, err)
+	assert.Equal(t, "255", prop.Value)
+	_, err = isyAPI.ReadIsyProperty(deckLightsID, "OL")
+	assert.Error(t, err)
+}
+
+// Z-Wave nodes are published by the kind of device of their node definition and can be controlled
+func TestZWave(t *testing.T) {
+	const lockID = "ZW002_1"
+	const dimmerID = "ZW003_1"
+	const motionID = "ZW004_1"
+	os.Remove(nodesFile)
+	zwaveConfig := &internal.IsyAppConfig{GatewayAddress: "file://" + testConfigFolder + "/firmware/isy994-5.3.0"}
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, zwaveConfig, "", false)
+	assert.NoError(t, err)
+
+	app := internal.NewIsyApp(zwaveConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	// the Insteon node is not affected by the Z-Wave nodes
+	assert.NotNil(t, pub.GetOutputByNodeHWID("2F 11 A3 1", types.OutputTypeDimmer, types.DefaultOutputInstance))
+
+	lockNode := pub.GetNodeByHWID(lockID)
+	require.NotNil(t, lockNode, "Z-Wave lock not found")
+	assert.Equal(t, "ZY002_153", lockNode.Attr[types.NodeAttrModel])
+	lockValue := pub.GetOutputValueByNodeHWID(lockID, types.OutputTypeLocked, types.DefaultOutputInstance)
+	require.NotNil(t, lockValue)
+	assert.Equal(t, "true", lockValue.Value)
+	batteryValue := pub.GetOutputValueByNodeHWID(lockID, types.OutputTypeBattery, "BATLVL")
+	require.NotNil(t, batteryValue)
+	assert.Equal(t, "87", batteryValue.Value)
+
+	dimmerOutput := pub.GetOutputByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance)
+	require.NotNil(t, dimmerOutput, "Z-Wave dimmer not found")
+	motionValue := pub.GetOutputValueByNodeHWID(motionID, types.OutputTypeMotion, types.DefaultOutputInstance)
+	require.NotNil(t, motionValue, "Z-Wave motion sensor not found")
+	assert.Equal(t, "false", motionValue.Value)
+	assert.Nil(t, pub.GetInputByNodeHWID(motionID, types.InputTypeSwitch, types.DefaultInputInstance))
+
+	// unlock the door and dim the light
+	lockInput := pub.GetInputByNodeHWID(lockID, types.InputTypeLock, types.DefaultInputInstance)
+	require.NotNil(t, lockInput)
+	assert.NoError(t, app.HandleZWaveInput(lockInput, "false"))
+	dimmerInput := pub.GetInputByNodeHWID(dimmerID, types.InputTypeDimmer, types.DefaultInputInstance)
+	require.NotNil(t, dimmerInput)
+	assert.NoError(t, app.HandleZWaveInput(dimmerInput, "25"))
+	assert.Error(t, app.HandleZWaveInput(dimmerInput, "bright"))
+	app.Poll(pub)
+	lockValue = pub.GetOutputValueByNodeHWID(lockID, types.OutputTypeLocked, types.DefaultOutputInstance)
+	assert.Equal(t, "false", lockValue.Value)
+	dimmerValue := pub.GetOutputValueByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance)
+	assert.Equal(t, "25", dimmerValue.Value)
+	// levels outside 0-100 are limited
+	assert.NoError(t, app.HandleZWaveInput(dimmerInput, "250"))
+	app.Poll(pub)
+	dimmerValue = pub.GetOutputValueByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance)
+	assert.Equal(t, "100", dimmerValue.Value)
+
+	// events without a unit of measure don't change the kind of device
+	app.HandleIsyEvent(&internal.IsyEvent{Control: "ST", Action: "100", Node: lockID})
+	assert.Nil(t, pub.GetOutputByNodeHWID(lockID, types.OutputTypeSwitch, types.DefaultOutputInstance))
+	lockValue = pub.GetOutputValueByNodeHWID(lockID, types.OutputTypeLocked, types.DefaultOutputInstance)
+	require.NotNil(t, lockValue)
+
+	pub.Stop()
+}
+
+// Firmware 5.x node definitions generate the outputs and command inputs of a node
+func TestNodeDefs(t *testing.T) {
+	const dimmerID = "2F 11 A3 1"
+	isyAPI := internal.NewIsyAPI("file://"+firmwareCorpus["isy994-5.3.0"]+"/..", "", "")
+	nodeDefs, err := isyAPI.ReadIsyNodeDefs()
+	require.NoError(t, err)
+	nodeDef := nodeDefs.NodeDef("DimmerLampSwitch_ADV")
+	require.NotNil(t, nodeDef)
+	assert.Nil(t, nodeDefs.NodeDef("ZY002_153"))
+	assert.NotNil(t, nodeDef.Command("RR"))
+	assert.Nil(t, nodeDef.Command("SECMD"))
+	rampRate := nodeDefs.EditorRange(nodeDef, "I_RR")
+	require.NotNil(t, rampRate)
+	assert.True(t, rampRate.IsEnum())
+	assert.Equal(t, 32, len(rampRate.EnumValues()))
+	assert.NoError(t, rampRate.Validate("28"))
+	assert.Error(t, rampRate.Validate("32"))
+	onLevel := nodeDefs.EditorRange(nodeDef, "I_OL")
+	require.NotNil(t, onLevel)
+	assert.NoError(t, onLevel.Validate("255"))
+	assert.Error(t, onLevel.Validate("256"))
+	assert.Error(t, onLevel.Validate("bright"))
+	assert.Equal(t, []string{"0", "1", "2", "5"}, (&internal.IsyEditorRange{Subset: "0-2,5"}).EnumValues())
+
+	os.Remove(nodesFile)
+	nodeDefConfig := &internal.IsyAppConfig{GatewayAddress: "file://" + testConfigFolder + "/firmware/isy994-5.3.0"}
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, nodeDefConfig, "", false)
+	assert.NoError(t, err)
+	app := internal.NewIsyApp(nodeDefConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	// each status property is an output, except for hidden properties
+	dimmerValue := pub.GetOutputValueByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance)
+	require.NotNil(t, dimmerValue)
+	assert.Equal(t, "75", dimmerValue.Value)
+	assert.NotNil(t, pub.GetOutputByNodeHWID(dimmerID, types.OutputTypeDimmer, "OL"))
+	assert.NotNil(t, pub.GetInputByNodeHWID(dimmerID, types.InputTypeDimmer, types.DefaultInputInstance))
+	dimmerNode := pub.GetNodeByHWID(dimmerID)
+	require.NotNil(t, dimmerNode)
+	assert.Equal(t, "DimmerLampSwitch_ADV", dimmerNode.Attr[types.NodeAttrModel])
+
+	// each accepted command is an input with its parameter range, except on, off and query
+	assert.Nil(t, pub.GetInputByNodeHWID(dimmerID, internal.InputTypeCommand, "DON"))
+	assert.Nil(t, pub.GetInputByNodeHWID(dimmerID, internal.InputTypeCommand, "QUERY"))
+	assert.Nil(t, pub.GetInputByNodeHWID(dimmerID, internal.InputTypeCommand, "BEEP"))
+	assert.NotNil(t, pub.GetInputByNodeHWID(dimmerID, internal.InputTypeCommand, "BRT"))
+	onLevelInput := pub.GetInputByNodeHWID(dimmerID, internal.InputTypeCommand, "OL")
+	require.NotNil(t, onLevelInput)
+	// the on level is given as a percentage, like its output
+	assert.Equal(t, "100", onLevelInput.Attr[internal.InputAttrMax])
+	rampRateInput := pub.GetInputByNodeHWID(dimmerID, internal.InputTypeCommand, "RR")
+	require.NotNil(t, rampRateInput)
+	assert.Contains(t, rampRateInput.Attr[internal.InputAttrEnum], "31")
+
+	assert.NoError(t, app.HandleNodeCommand(onLevelInput, "50"))
+	assert.Error(t, app.HandleNodeCommand(onLevelInput, "101"))
+	assert.Error(t, app.HandleNodeCommand(rampRateInput, "fast"))
+	app.Poll(pub)
+	onLevelValue := pub.GetOutputValueByNodeHWID(dimmerID, types.OutputTypeDimmer, "OL")
+	require.NotNil(t, onLevelValue)
+	assert.Equal(t, "50", onLevelValue.Value)
+
+	// status events update the property of the node definition
+	app.HandleIsyEvent(&internal.IsyEvent{Control: "ST", Action: "255", Node: dimmerID})
+	dimmerValue = pub.GetOutputValueByNodeHWID(dimmerID, types.OutputTypeDimmer, types.DefaultOutputInstance)
+	assert.Equal(t, "100", dimmerValue.Value)
+
+	pub.Stop()
+}
+
+// Nodes of node servers are published with the node definitions and NLS strings of their profile
+func TestNodeServers(t *testing.T) {
+	const stationID = "n001_st_1234"
+	isyAPI := internal.NewIsyAPI("file://"+firmwareCorpus["isy994-5.3.0"]+"/..", "", "")
+	nodeServers, err := isyAPI.ReadIsyNodeServers()
+	require.NoError(t, err)
+	require.Equal(t, 2, len(nodeServers.NodeServers))
+	assert.Equal(t, "1", nodeServers.NodeServers[0].Profile)
+	assert.Equal(t, "WeatherFlow", nodeServers.NodeServers[0].Name)
+	nodeDefs, err := isyAPI.ReadIsyNodeServerDefs("1")
+	require.NoError(t, err)
+	nodeDef := nodeDefs.NodeDef("WeatherFlow")
+	require.NotNil(t, nodeDef)
+	nls, err := isyAPI.ReadIsyNodeServerNLS("1")
+	require.NoError(t, err)
+	assert.Equal(t, "WeatherFlow Station", nls.NodeName(nodeDef))
+	assert.Equal(t, "Wind Speed", nls.StatusName(nodeDef, "GV1"))
+	assert.Equal(t, "Units", nls.CommandName(nodeDef, "UNITS"))
+	trend := nodeDefs.EditorRange(nodeDef, "WF_TREND")
+	require.NotNil(t, trend)
+	assert.Equal(t, "Rising", nls.EnumName(trend, "2"))
+	assert.Equal(t, "7", nls.EnumName(trend, "7"))
+	assert.Equal(t, "0", nls.EnumValue(trend, "falling"))
+	assert.False(t, nodeDefs.EditorRange(nodeDef, "WF_BOOL").IsEnum())
+	_, err = isyAPI.ReadIsyNodeServerNLS("2")
+	assert.Error(t, err)
+
+	os.Remove(nodesFile)
+	nodeServerConfig := &internal.IsyAppConfig{GatewayAddress: "file://" + testConfigFolder + "/firmware/isy994-5.3.0"}
+	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, nodeServerConfig, "", false)
+	assert.NoError(t, err)
+	app := internal.NewIsyApp(nodeServerConfig, pub)
+	pub.Start()
+	app.Poll(pub)
+
+	// the node is labelled with its node server and properties are named by the NLS strings
+	station := pub.GetNodeByHWID(stationID)
+	require.NotNil(t, station, "Node server node not found")
+	assert.Equal(t, "WeatherFlow", station.Attr[internal.NodeAttrNodeServer])
+	assert.Equal(t, "WeatherFlow Station", station.Attr[types.NodeAttrDescription])
+	windOutput := pub.GetOutputByNodeHWID(stationID, "windSpeed", types.DefaultOutputInstance)
+	require.NotNil(t, windOutput)
+	assert.Equal(t, "Wind Speed", windOutput.Description)
+	windValue := pub.GetOutputValueByNodeHWID(stationID, "windSpeed", types.DefaultOutputInstance)
+	require.NotNil(t, windValue)
+	assert.Equal(t, "12.4", windValue.Value)
+	trendValue := pub.GetOutputValueByNodeHWID(stationID, "pressureTrend", types.DefaultOutputInstance)
+	require.NotNil(t, trendValue)
+	assert.Equal(t, "Rising", trendValue.Value)
+	trendOutput := pub.GetOutputByNodeHWID(stationID, "pressureTrend", types.DefaultOutputInstance)
+	assert.Equal(t, []string{"Falling", "Steady", "Rising"}, trendOutput.EnumValues)
+
+	// commands are inputs with their NLS name and enum names
+	unitsInput := pub.GetInputByNodeHWID(stationID, internal.InputTypeCommand, "UNITS")
+	require.NotNil(t, unitsInput)
+	assert.Equal(t, "Units", unitsInput.Attr[types.NodeAttrDescription])
+	assert.Equal(t, "Metric,Imperial", unitsInput.Attr[internal.InputAttrEnum])
+	assert.NoError(t, app.HandleNodeCommand(unitsInput, "Imperial"))
+	assert.NoError(t, app.HandleNodeCommand(unitsInput, "0"))
+	assert.Error(t, app.HandleNodeCommand(unitsInput, "Nautical"))
+
+	pub.Stop()
+}
+
+// The parsers decode the responses of each firmware version as in the golden files
+func TestGoldenParsers(t *testing.T) {
+	for corpus, folder := range firmwareCorpus {
+		for filename, parse := range xmlParsers {
+			data, err := ioutil.ReadFile(path.Join(folder, filename))
+			if os.IsNotExist(err) {
+				continue
+			}
+			require.NoError(t, err)
+			result, err := parse(data)
+			require.NoErrorf(t, err, "Parsing %s of %s", filename, corpus)
+			actual, err := json.MarshalIndent(result, "", "  ")
+			require.NoError(t, err)
+
+			goldenFile := path.Join(testConfigFolder, "golden", corpus, filename+".json")
+			if *updateGolden {
+				require.NoError(t, os.MkdirAll(path.Dir(goldenFile), 0755))
+				require.NoError(t, ioutil.WriteFile(goldenFile, append(actual, '\n'), 0644))
+			}
+			expected, err := ioutil.ReadFile(goldenFile)
+			require.NoErrorf(t, err, "Missing golden file. Run with -update to create it.")
+			assert.JSONEqf(t, string(expected), string(actual), "Parsing %s of %s", filename, corpus)
+		}
+	}
+	// malformed responses are errors rather than empty results
+	for filename, parse := range xmlParsers {
+		for _, data := range malformedXML {
+			result, err := parse([]byte(data))
+			if err == nil {
+				assert.Failf(t, "Expected an error", "%s parser accepted '%s' as %+v", filename, data, result)
+			}
+		}
+	}
+}
+
 func TestStartStop(t *testing.T) {
 	pub, err := publisher.NewAppPublisher(appID, testConfigFolder, appConfig, "", false)
 	assert.NoError(t, err)
@@ -0,0 +1,96 @@
+// Package internal with the capture of ISY requests and responses as simulation files
+package internal
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SimulationFilename returns the name of the file with the response of a REST request
+// in the simulation folder, <folder>/<restPath>.xml. Query parameters are not part of the file name.
+// Requests for files, like the .txt and .xml files of node server profiles, use the file name as is.
+func SimulationFilename(folder string, restPath string) string {
+	filePath := strings.SplitN(restPath, "?", 2)[0]
+	if ext := path.Ext(filePath); ext != ".xml" && ext != ".txt" {
+		filePath += ".xml"
+	}
+	return path.Join(folder, filePath)
+}
+
+// SetCaptureFolder captures all requests to the ISY in the given folder
+// Each response is written to the file that simulation mode reads for its request path, so the
+// folder can be used as the gateway address file://<folder> to reproduce the gateway offline.
+// This includes command paths and the event stream. Only responses are captured, so the
+// Authorization header with the login name and password is not. Neither is the query of the
+// request URL, which can hold secrets like the Elk user code, as it isn't part of the file name.
+// Use an empty folder to stop capturing.
+func (isyAPI *IsyAPI) SetCaptureFolder(folder string) {
+	isyAPI.captureMutex.Lock()
+	defer isyAPI.captureMutex.Unlock()
+	isyAPI.captureFolder = folder
+}
+
+// capture writes the response of a request to the capture folder, if capturing
+// Later responses to the same path replace earlier ones.
+func (isyAPI *IsyAPI) capture(restPath string, response []byte) {
+	isyAPI.captureMutex.Lock()
+	defer isyAPI.captureMutex.Unlock()
+	if isyAPI.captureFolder == "" {
+		return
+	}
+	filename := SimulationFilename(isyAPI.captureFolder, restPath)
+	err := os.MkdirAll(path.Dir(filename), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(filename, response, 0644)
+	}
+	if err != nil {
+		logrus.Warnf("capture: Unable to write capture file %s: %v", filename, err)
+	}
+}
+
+// captureStream returns a stream that also writes what is read to the capture file of the
+// request path, or the stream itself if not capturing
+func (isyAPI *IsyAPI) captureStream(restPath string, stream io.ReadWriteCloser) io.ReadWriteCloser {
+	isyAPI.captureMutex.Lock()
+	defer isyAPI.captureMutex.Unlock()
+	if isyAPI.captureFolder == "" {
+		return stream
+	}
+	filename := SimulationFilename(isyAPI.captureFolder, restPath)
+	err := os.MkdirAll(path.Dir(filename), 0755)
+	var file *os.File
+	if err == nil {
+		file, err = os.Create(filename)
+	}
+	if err != nil {
+		logrus.Warnf("captureStream: Unable to create capture file %s: %v", filename, err)
+		return stream
+	}
+	return &capturedStream{ReadWriteCloser: stream, file: file}
+}
+
+// capturedStream is a stream that writes what is read to a capture file
+type capturedStream struct {
+	io.ReadWriteCloser
+	file *os.File
+}
+
+// Read from the stream and write the data to the capture file
+func (stream *capturedStream) Read(buffer []byte) (n int, err error) {
+	n, err = stream.ReadWriteCloser.Read(buffer)
+	if n > 0 {
+		_, _ = stream.file.Write(buffer[:n])
+	}
+	return n, err
+}
+
+// Close the stream and the capture file
+func (stream *capturedStream) Close() error {
+	_ = stream.file.Close()
+	return stream.ReadWriteCloser.Close()
+}
@@ -0,0 +1,78 @@
+// Package internal with methods for reading the ISY climate and irrigation data
+package internal
+
+import (
+	"strings"
+
+	"github.com/iotdomain/iotdomain-go/types"
+)
+
+// IsyClimate with the weather and irrigation data of the climate module. Example:
+// <climate>
+//    <Temperature>72.5 °F</Temperature>
+//    <Humidity>45 %</Humidity>
+//    <Pressure>29.92 inHg</Pressure>
+//    <Dew_Point>50.1 °F</Dew_Point>
+//    <Wind_Speed>5 mph</Wind_Speed>
+//    <Wind_Direction>270 °</Wind_Direction>
+//    <Total_Rain_Today>0.12 inches</Total_Rain_Today>
+//    <Evapotranspiration>0.18 inches/day</Evapotranspiration>
+//    <Irrigation_Requirement>0.06 inches</Irrigation_Requirement>
+//    <Water_Deficit_Yesterday>0.04 inches</Water_Deficit_Yesterday>
+//    ...
+// </climate>
+type IsyClimate struct {
+	Temperature           string `xml:"Temperature"`
+	TemperatureHigh       string `xml:"Temperature_High"`
+	TemperatureLow        string `xml:"Temperature_Low"`
+	Humidity              string `xml:"Humidity"`
+	Pressure              string `xml:"Pressure"`
+	DewPoint              string `xml:"Dew_Point"`
+	WindSpeed             string `xml:"Wind_Speed"`
+	WindDirection         string `xml:"Wind_Direction"`
+	GustSpeed             string `xml:"Gust_Speed"`
+	TotalRainToday        string `xml:"Total_Rain_Today"`
+	Evapotranspiration    string `xml:"Evapotranspiration"`
+	IrrigationRequirement string `xml:"Irrigation_Requirement"`
+	WaterDeficitYesterday string `xml:"Water_Deficit_Yesterday"`
+}
+
+// climateUnits maps the units used by the climate module to the iotdomain units
+var climateUnits = map[string]types.Unit{
+	"°F":     types.UnitFahrenheit,
+	"F":      types.UnitFahrenheit,
+	"°C":     types.UnitCelcius,
+	"C":      types.UnitCelcius,
+	"%":      types.UnitPercent,
+	"mph":    types.UnitMph,
+	"kph":    types.UnitKmPerHour,
+	"km/h":   types.UnitKmPerHour,
+	"°":      types.UnitDegree,
+	"in":     types.UnitInch,
+	"inches": types.UnitInch,
+	"mm":     types.UnitMillimeter,
+}
+
+// ParseClimateValue splits a climate value into its number and unit, eg "72.5 °F" is 72.5 in Fahrenheit
+// Units that are not known, like "inches/day", are returned as is.
+func ParseClimateValue(climateValue string) (value string, unit types.Unit) {
+	fields := strings.Fields(climateValue)
+	if len(fields) == 0 {
+		return "", types.UnitNone
+	} else if len(fields) == 1 {
+		return fields[0], types.UnitNone
+	}
+	unitText := strings.Join(fields[1:], " ")
+	unit, found := climateUnits[unitText]
+	if !found {
+		unit = types.Unit(unitText)
+	}
+	return fields[0], unit
+}
+
+// ReadIsyClimate reads the climate and irrigation data from the climate module
+func (isyAPI *IsyAPI) ReadIsyClimate() (*IsyClimate, error) {
+	isyClimate := IsyClimate{}
+	err := isyAPI.isyRequest("/rest/climate", &isyClimate)
+	return &isyClimate, err
+}
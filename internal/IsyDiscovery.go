@@ -0,0 +1,84 @@
+// Package internal with the discovery of ISY gateways on the local network using SSDP
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// SSDPAddress is the multicast address of UPnP SSDP discovery
+const SSDPAddress = "239.255.255.250:1900"
+
+// DefaultDiscoveryTimeout is the default time to wait for ISY gateways to respond to a discovery request
+const DefaultDiscoveryTimeout = 3 * time.Second
+
+// RediscoverAfterFailures is the number of consecutive failed reads of a discovered gateway
+// after which it is discovered again, as DHCP can move it to another address
+const RediscoverAfterFailures = 3
+
+// isySearchTarget is the UPnP device type announced by the ISY, see <upnpSpecs> in config.xml
+const isySearchTarget = "urn:udi-com:device:X_Insteon_Lighting_Device:1"
+
+// ssdpSearchRequest is the M-SEARCH request for the ISY device type
+const ssdpSearchRequest = "M-SEARCH * HTTP/1.1\r\n" +
+	"HOST: 239.255.255.250:1900\r\n" +
+	"MAN: \"ssdp:discover\"\r\n" +
+	"MX: 2\r\n" +
+	"ST: " + isySearchTarget + "\r\n\r\n"
+
+// DiscoverIsy searches the local network for an ISY gateway
+// ssdpAddress is the address to send the search request to, normally SSDPAddress.
+// This returns the host and port of the URL base of the first ISY that responds.
+func DiscoverIsy(ssdpAddress string, timeout time.Duration) (address string, err error) {
+	destAddr, err := net.ResolveUDPAddr("udp4", ssdpAddress)
+	if err != nil {
+		return "", err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	_, err = conn.WriteTo([]byte(ssdpSearchRequest), destAddr)
+	if err != nil {
+		return "", err
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	buffer := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return "", errors.New("DiscoverIsy: No ISY gateway responded")
+		}
+		address, err = parseSSDPResponse(buffer[:n])
+		if err == nil {
+			return address, nil
+		}
+	}
+}
+
+// parseSSDPResponse returns the host and port of the LOCATION of an ISY SSDP search response
+// Responses from other devices return an error.
+func parseSSDPResponse(response []byte) (address string, err error) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(response)), nil)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	if !strings.Contains(resp.Header.Get("ST"), "X_Insteon_Lighting_Device") &&
+		!strings.Contains(resp.Header.Get("USN"), "X_Insteon_Lighting_Device") {
+		return "", fmt.Errorf("parseSSDPResponse: Not an ISY: %s", resp.Header.Get("ST"))
+	}
+	location, err := url.Parse(resp.Header.Get("LOCATION"))
+	if err != nil || location.Host == "" {
+		return "", fmt.Errorf("parseSSDPResponse: Invalid location '%s'", resp.Header.Get("LOCATION"))
+	}
+	return location.Host, nil
+}
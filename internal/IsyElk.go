@@ -0,0 +1,109 @@
+// Package internal with methods for the ISY Elk security system module
+package internal
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Elk area event types as used in the Elk status and events
+const (
+	ElkAreaEventAlarmState = "1"
+	ElkAreaEventArmUpState = "2"
+	ElkAreaEventArmedState = "3"
+)
+
+// Elk zone event types as used in the Elk status and events
+const (
+	ElkZoneEventLogicalStatus  = "51"
+	ElkZoneEventPhysicalStatus = "52"
+	ElkZoneEventVoltage        = "53"
+)
+
+// ElkArmedStates with the names of the Elk area armed state values
+var ElkArmedStates = []string{"disarmed", "armedAway", "armedStay", "armedStayInstant",
+	"armedNight", "armedNightInstant", "armedVacation"}
+
+// ElkArmTypes with the arm type codes by name, as used in the arm command
+var ElkArmTypes = map[string]string{
+	"away": "1", "stay": "2", "stayInstant": "3", "night": "4", "nightInstant": "5", "vacation": "6",
+}
+
+// ElkZoneStatus with the names of the Elk zone logical status values
+var ElkZoneStatus = []string{"normal", "trouble", "violated", "bypassed"}
+
+// IsyElkTopology with the Elk areas and zones known to the ISY. Example:
+// <topology>
+//    <areas>
+//       <area id="1" name="House">
+//          <zone id="1" name="Front door" alarmDef="1"/>
+//       </area>
+//    </areas>
+// </topology>
+type IsyElkTopology struct {
+	Areas []struct {
+		ID    string `xml:"id,attr"`
+		Name  string `xml:"name,attr"`
+		Zones []struct {
+			ID       string `xml:"id,attr"`
+			Name     string `xml:"name,attr"`
+			AlarmDef string `xml:"alarmDef,attr"`
+		} `xml:"zone"`
+	} `xml:"areas>area"`
+}
+
+// IsyElkStatus with the Elk area and zone status. Example:
+// <Elk>
+//    <ae type="3" area="1" val="0"/>
+//    <ze type="51" zone="1" val="2"/>
+// </Elk>
+type IsyElkStatus struct {
+	AreaEvents []IsyElkAreaEvent `xml:"ae"`
+	ZoneEvents []IsyElkZoneEvent `xml:"ze"`
+}
+
+// IsyElkAreaEvent with an area status value
+type IsyElkAreaEvent struct {
+	Type  string `xml:"type,attr"`
+	Area  string `xml:"area,attr"`
+	Value string `xml:"val,attr"`
+}
+
+// IsyElkZoneEvent with a zone status value
+type IsyElkZoneEvent struct {
+	Type  string `xml:"type,attr"`
+	Zone  string `xml:"zone,attr"`
+	Value string `xml:"val,attr"`
+}
+
+// ReadElkTopology reads the Elk areas and zones
+func (isyAPI *IsyAPI) ReadElkTopology() (*IsyElkTopology, error) {
+	topology := IsyElkTopology{}
+	err := isyAPI.isyRequest("/rest/elk/get/topology", &topology)
+	return &topology, err
+}
+
+// ReadElkStatus reads the Elk area and zone status
+func (isyAPI *IsyAPI) ReadElkStatus() (*IsyElkStatus, error) {
+	status := IsyElkStatus{}
+	err := isyAPI.isyRequest("/rest/elk/get/status", &status)
+	return &status, err
+}
+
+// WriteElkArm arms or disarms an Elk area
+// areaID is the Elk area number
+// armType is the arm type name from ElkArmTypes, or "disarm" to disarm the area
+// code is the Elk user code
+func (isyAPI *IsyAPI) WriteElkArm(areaID string, armType string, code string) error {
+	var restPath string
+	if armType == "disarm" {
+		restPath = fmt.Sprintf("/rest/elk/area/%s/cmd/disarm?code=%s", areaID, url.QueryEscape(code))
+	} else {
+		armCode, found := ElkArmTypes[armType]
+		if !found {
+			return fmt.Errorf("WriteElkArm: Unknown arm type '%s'", armType)
+		}
+		restPath = fmt.Sprintf("/rest/elk/area/%s/cmd/arm?armType=%s&code=%s", areaID, armCode, url.QueryEscape(code))
+	}
+	return isyAPI.writeCommand(restPath, elkAreaHWID(areaID), armType)
+}
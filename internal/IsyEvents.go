@@ -0,0 +1,323 @@
+// Package internal with methods for subscribing to the ISY99x event stream
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// isySubscribeRequest is the SOAP request that asks the ISY to report events over the same socket
+const isySubscribeRequest = "<s:Envelope><s:Body>" +
+	"<u:Subscribe xmlns:u='urn:udi-com:service:X_Insteon_Lighting_Service:1'>" +
+	"<reportURL>REUSE_SOCKET</reportURL>" +
+	"<duration>infinite</duration>" +
+	"</u:Subscribe></s:Body></s:Envelope>\r\n"
+
+// isySubscribeTimeout is the time to wait for the ISY to respond to the subscription request
+const isySubscribeTimeout = 10 * time.Second
+
+// The delay before resubscribing after the event stream ended doubles on each failed attempt
+const (
+	resubscribeMinDelay = time.Second
+	resubscribeMaxDelay = 5 * time.Minute
+)
+
+// isySubscribePath is the path of the event stream in simulation and capture files
+const isySubscribePath = "/rest/subscribe"
+
+// IsyEvent with an event reported by the ISY on the event stream. Example:
+// <Event seqnum="12" sid="uuid:41">
+//    <control>ST</control>
+//    <action uom="100" prec="0">255</action>
+//    <node>13 55 D3 1</node>
+//    <eventInfo></eventInfo>
+//    <fmtAct>100%</fmtAct>
+// </Event>
+// Control is the property or command ID, like ST or DON. System events have an underscore
+// prefix, like _0 for heartbeat and _1 for trigger events, and no node.
+// Firmware 4.x and up report the unit of measure and precision of property values, and 5.x
+// also the formatted value.
+type IsyEvent struct {
+	SeqNum    string `xml:"seqnum,attr"`
+	SID       string `xml:"sid,attr"`
+	Control   string `xml:"control"`
+	Action    string `xml:"action"`
+	UOM       string `xml:"-"` // unit of measure of the action value, if reported
+	Precision string `xml:"-"` // number of decimals in the action value, if reported
+	Node      string `xml:"node"`
+	EventInfo struct {
+		Content string `xml:",innerxml"`
+	} `xml:"eventInfo"`
+	Formatted string `xml:"fmtAct"` // action value as formatted by the ISY, firmware 5.x
+}
+
+// UnmarshalXML decodes the event including the attributes of the action
+func (event *IsyEvent) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	// the plain event has no UnmarshalXML method
+	type plainEvent IsyEvent
+	var decoded struct {
+		plainEvent
+		Action struct {
+			Value     string `xml:",chardata"`
+			UOM       string `xml:"uom,attr"`
+			Precision string `xml:"prec,attr"`
+		} `xml:"action"`
+	}
+	err := decoder.DecodeElement(&decoded, &start)
+	if err != nil {
+		return err
+	}
+	*event = IsyEvent(decoded.plainEvent)
+	event.Action = decoded.Action.Value
+	event.UOM = decoded.Action.UOM
+	event.Precision = decoded.Action.Precision
+	return nil
+}
+
+// property returns the property of a node that changed with the event
+func (event *IsyEvent) property() IsyProp {
+	return IsyProp{ID: event.Control, Value: event.Action, Formatted: event.Formatted,
+		UOM: event.UOM, Precision: event.Precision}
+}
+
+// unmarshalEventInfo decodes the XML elements in the event info into result
+func unmarshalEventInfo(event *IsyEvent, result interface{}) error {
+	return xml.Unmarshal([]byte("<eventInfo>"+event.EventInfo.Content+"</eventInfo>"), result)
+}
+
+// Subscribe to the ISY event stream
+// The handler is invoked from a separate goroutine for each event received, until the stream
+// is closed. When the ISY drops the connection, for example when it reboots, the stream is
+// resubscribed with an increasing delay. In simulation mode the events are read once from
+// <address>/rest/subscribe.xml.
+// This returns the stream so it can be closed by the caller
+func (isyAPI *IsyAPI) Subscribe(handler func(event *IsyEvent)) (io.Closer, error) {
+	if strings.HasPrefix(isyAPI.address, "file://") {
+		err := isyAPI.simulator.request(isySubscribePath)
+		if err != nil {
+			return nil, err
+		}
+		filename := SimulationFilename(isyAPI.address[7:], isySubscribePath)
+		stream, err := os.Open(filename)
+		if err != nil {
+			logrus.Errorf("Subscribe: Unable to read ISY events from file %s: %v", filename, err)
+			return nil, err
+		}
+		go readIsyEvents(stream, handler)
+		return stream, nil
+	}
+	stream, err := isyAPI.sendSubscribe()
+	if err != nil {
+		return nil, err
+	}
+	subscription := &isySubscription{
+		isyAPI:  isyAPI,
+		handler: handler,
+		stream:  stream,
+		closed:  make(chan struct{}),
+	}
+	go subscription.run(stream)
+	return subscription, nil
+}
+
+// isySubscription with the event stream of a subscription that is renewed when the stream ends
+type isySubscription struct {
+	isyAPI  *IsyAPI
+	handler func(event *IsyEvent)
+	mutex   sync.Mutex
+	stream  io.Closer     // the current event stream
+	closed  chan struct{} // closed when the subscription is closed
+}
+
+// Close the subscription and its event stream
+func (subscription *isySubscription) Close() error {
+	subscription.mutex.Lock()
+	defer subscription.mutex.Unlock()
+	select {
+	case <-subscription.closed:
+		return nil
+	default:
+	}
+	close(subscription.closed)
+	return subscription.stream.Close()
+}
+
+// run reads the event stream and resubscribes when it ends, until the subscription is closed
+func (subscription *isySubscription) run(stream io.ReadWriteCloser) {
+	for {
+		readIsyEvents(stream, subscription.handler)
+		stream = subscription.resubscribe()
+		if stream == nil {
+			return
+		}
+	}
+}
+
+// resubscribe waits and subscribes again until it succeeds or the subscription is closed
+// Returns the new stream, or nil if the subscription is closed.
+func (subscription *isySubscription) resubscribe() io.ReadWriteCloser {
+	delay := resubscribeMinDelay
+	for {
+		select {
+		case <-subscription.closed:
+			return nil
+		case <-time.After(delay):
+		}
+		logrus.Infof("Subscribe: Resubscribing to the ISY event stream")
+		stream, err := subscription.isyAPI.sendSubscribe()
+		if err == nil {
+			subscription.mutex.Lock()
+			defer subscription.mutex.Unlock()
+			select {
+			case <-subscription.closed:
+				_ = stream.Close()
+				return nil
+			default:
+			}
+			subscription.stream = stream
+			return stream
+		}
+		delay *= 2
+		if delay > resubscribeMaxDelay {
+			delay = resubscribeMaxDelay
+		}
+	}
+}
+
+// sendSubscribe opens a connection to the ISY and posts the SOAP subscription request
+// The subscription is accepted if the ISY responds with status OK and a subscription ID.
+// This returns the connection with the event stream that follows the response.
+func (isyAPI *IsyAPI) sendSubscribe() (io.ReadWriteCloser, error) {
+	hostPort := isyAPI.address
+	if _, _, err := net.SplitHostPort(hostPort); err != nil {
+		hostPort = net.JoinHostPort(hostPort, "80")
+	}
+	conn, err := net.Dial("tcp", hostPort)
+	if err != nil {
+		logrus.Warnf("Subscribe: Unable to connect to ISY device at %s: %v", hostPort, err)
+		return nil, err
+	}
+	auth := base64.StdEncoding.EncodeToString([]byte(isyAPI.login + ":" + isyAPI.password))
+	request := "POST /services HTTP/1.1\r\n" +
+		"Content-Type: text/xml; charset=utf-8\r\n" +
+		"Authorization: Basic " + auth + "\r\n" +
+		fmt.Sprintf("Content-Length: %d\r\n", len(isySubscribeRequest)) +
+		"SOAPAction: urn:udi-com:device:X_Insteon_Lighting_Service:1#Subscribe\r\n" +
+		"\r\n" + isySubscribeRequest
+	_, err = conn.Write([]byte(request))
+	if err != nil {
+		logrus.Warnf("Subscribe: Unable to send subscription request to %s: %v", hostPort, err)
+		_ = conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	_ = conn.SetReadDeadline(time.Now().Add(isySubscribeTimeout))
+	err = readSubscribeResponse(reader)
+	if err != nil {
+		logrus.Warnf("Subscribe: Subscription to %s failed: %v", hostPort, err)
+		_ = conn.Close()
+		return nil, err
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	stream := &subscribedConn{Conn: conn, reader: reader}
+	return isyAPI.captureStream(isySubscribePath, stream), nil
+}
+
+// readSubscribeResponse reads the response to the subscription request. Example:
+// <s:Envelope><s:Body>
+//    <SubscriptionResponse><SID>uuid:41</SID><duration>0</duration></SubscriptionResponse>
+// </s:Body></s:Envelope>
+// Only the SOAP envelope is read so the events that follow it stay in the reader.
+func readSubscribeResponse(reader *bufio.Reader) error {
+	response, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("subscription rejected: %s", response.Status)
+	}
+	var body []byte
+	for endOfEnvelope := false; !endOfEnvelope; {
+		element, err := reader.ReadBytes('>')
+		if err != nil {
+			return fmt.Errorf("invalid subscription response: %v", err)
+		}
+		body = append(body, element...)
+		endOfEnvelope = bytes.HasSuffix(element, []byte("Envelope>")) && bytes.Contains(element, []byte("</"))
+	}
+	if !bytes.Contains(body, []byte("<SID>")) {
+		return fmt.Errorf("subscription rejected: %s", body)
+	}
+	return nil
+}
+
+// subscribedConn with the connection of the event stream
+// Events are read from the reader as it can already hold the events that followed the response.
+type subscribedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// Read events from the buffered connection
+func (conn *subscribedConn) Read(data []byte) (int, error) {
+	return conn.reader.Read(data)
+}
+
+// readIsyEvents reads events from the stream until it is closed or fails
+// The ISY posts each event as a separate HTTP message. Rather than parsing the HTTP envelope
+// this scans the stream for <Event> elements.
+func readIsyEvents(stream io.Reader, handler func(event *IsyEvent)) {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 4096), 1024*1024)
+	scanner.Split(splitIsyEvents)
+	for scanner.Scan() {
+		event := &IsyEvent{}
+		err := xml.Unmarshal(scanner.Bytes(), event)
+		if err != nil {
+			logrus.Warnf("readIsyEvents: Ignored invalid event: %v", err)
+			continue
+		}
+		handler(event)
+	}
+	if err := scanner.Err(); err != nil {
+		logrus.Warnf("readIsyEvents: Event stream ended: %v", err)
+	}
+}
+
+// splitIsyEvents is a bufio.SplitFunc that returns each <Event>...</Event> element in the stream
+func splitIsyEvents(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	const startTag = "<Event"
+	const endTag = "</Event>"
+	start := bytes.Index(data, []byte(startTag))
+	if start < 0 {
+		if atEOF {
+			return len(data), nil, io.EOF
+		}
+		// keep the tail as it can contain the start of a partial tag
+		if len(data) > len(startTag) {
+			return len(data) - len(startTag), nil, nil
+		}
+		return 0, nil, nil
+	}
+	end := bytes.Index(data[start:], []byte(endTag))
+	if end < 0 {
+		if atEOF {
+			return len(data), nil, io.EOF
+		}
+		return start, nil, nil
+	}
+	end += start + len(endTag)
+	return end, data[start:end], nil
+}
@@ -0,0 +1,96 @@
+// Package internal with the ISY gateway modules and the subsystems they enable
+package internal
+
+import (
+	"sort"
+	"strings"
+)
+
+// ISY feature IDs as listed in the gateway configuration
+const (
+	FeatureElectricityMonitor = "21011"
+	FeatureCurrentCostMeter   = "21014"
+	FeatureWeather            = "21020"
+	FeatureNetworking         = "21040"
+	FeatureAMIElectricity     = "21050"
+	FeatureSEP                = "21051"
+	FeatureX10                = "21060"
+	FeatureBroadbandSEP       = "21080"
+	FeatureElk                = "21090"
+	FeatureIrrigation         = "23000"
+)
+
+// IsySubsystems with the subsystems that are enabled on a gateway
+type IsySubsystems struct {
+	Programs   bool // programs and triggers
+	Variables  bool // integer and state variables
+	Elk        bool // Elk security system
+	Climate    bool // weather and irrigation/ETo data
+	Energy     bool // energy metering modules
+	Networking bool // network resources
+}
+
+// IsInstalled returns true if the feature with the given ID is installed on the gateway
+func (isyDevice *IsyDevice) IsInstalled(featureID string) bool {
+	for _, feature := range isyDevice.Configuration.Features {
+		if feature.ID == featureID {
+			return feature.IsInstalled
+		}
+	}
+	return false
+}
+
+// InstalledFeatures returns the description of the installed features
+func (isyDevice *IsyDevice) InstalledFeatures() []string {
+	installed := make([]string, 0)
+	for _, feature := range isyDevice.Configuration.Features {
+		if feature.IsInstalled {
+			installed = append(installed, feature.Description)
+		}
+	}
+	return installed
+}
+
+// AvailableFeatures returns the description of the features that are available but not installed
+func (isyDevice *IsyDevice) AvailableFeatures() []string {
+	available := make([]string, 0)
+	for _, feature := range isyDevice.Configuration.Features {
+		if feature.IsAvailable && !feature.IsInstalled {
+			available = append(available, feature.Description)
+		}
+	}
+	return available
+}
+
+// Subsystems returns the subsystems that are enabled based on the gateway configuration
+// The Elk module can be present as a security system without being listed as an installed feature.
+func (isyDevice *IsyDevice) Subsystems() IsySubsystems {
+	config := isyDevice.Configuration
+	return IsySubsystems{
+		Programs:  config.Triggers,
+		Variables: config.Variables,
+		Elk:       strings.ToUpper(config.SecSys.Type) == "ELK" || isyDevice.IsInstalled(FeatureElk),
+		Climate:   isyDevice.IsInstalled(FeatureWeather) || isyDevice.IsInstalled(FeatureIrrigation),
+		Energy: isyDevice.IsInstalled(FeatureElectricityMonitor) ||
+			isyDevice.IsInstalled(FeatureCurrentCostMeter) ||
+			isyDevice.IsInstalled(FeatureAMIElectricity) ||
+			isyDevice.IsInstalled(FeatureSEP) ||
+			isyDevice.IsInstalled(FeatureBroadbandSEP),
+		Networking: isyDevice.IsInstalled(FeatureNetworking),
+	}
+}
+
+// Names returns the names of the enabled subsystems
+func (subsystems IsySubsystems) Names() []string {
+	names := make([]string, 0)
+	for name, enabled := range map[string]bool{
+		"programs": subsystems.Programs, "variables": subsystems.Variables, "elk": subsystems.Elk,
+		"climate": subsystems.Climate, "energy": subsystems.Energy, "networking": subsystems.Networking,
+	} {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
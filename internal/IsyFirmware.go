@@ -0,0 +1,58 @@
+// Package internal with the firmware versions of the ISY and their differences
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IsyFirmware with the version of the ISY firmware, eg 5.3.0
+// The ISY99 runs firmware 3.x or older. The ISY994 runs 4.x and 5.x and the eisy runs 5.x and up.
+// Firmware 5.x reports all properties of a node, supports Z-Wave and node servers, and adds the
+// get and set property commands.
+type IsyFirmware struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// AtLeast returns true if the firmware version is the given major.minor version or newer
+func (firmware IsyFirmware) AtLeast(major int, minor int) bool {
+	return firmware.Major > major || (firmware.Major == major && firmware.Minor >= minor)
+}
+
+// String returns the version as major.minor.patch
+func (firmware IsyFirmware) String() string {
+	return fmt.Sprintf("%d.%d.%d", firmware.Major, firmware.Minor, firmware.Patch)
+}
+
+// ParseFirmwareVersion parses the app_version of the gateway configuration, eg "5.3.0"
+// Suffixes like "5.0.16C" or "4.7.3-beta" are ignored. An invalid version returns 0.0.0.
+func ParseFirmwareVersion(appVersion string) IsyFirmware {
+	numbers := [3]int{}
+	parts := strings.SplitN(strings.TrimSpace(appVersion), ".", 3)
+	for i, part := range parts {
+		digits := strings.IndexFunc(part, func(r rune) bool { return r < '0' || r > '9' })
+		if digits >= 0 {
+			part = part[:digits]
+		}
+		number, err := strconv.Atoi(part)
+		if err != nil {
+			break
+		}
+		numbers[i] = number
+	}
+	return IsyFirmware{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}
+}
+
+// Firmware returns the firmware version of the gateway
+func (isyDevice *IsyDevice) Firmware() IsyFirmware {
+	return ParseFirmwareVersion(isyDevice.Configuration.AppVersion)
+}
+
+// hasPropertyCommands returns true if the firmware supports the /rest/nodes/<id>/get and set
+// property commands, firmware 5.x and up
+func (firmware IsyFirmware) hasPropertyCommands() bool {
+	return firmware.AtLeast(5, 0)
+}
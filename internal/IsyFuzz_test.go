@@ -0,0 +1,114 @@
+//go:build go1.18
+// +build go1.18
+
+package internal_test
+
+import (
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/iotdomain/isy99/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fuzzXMLParser fuzzes a parser with the corpus responses and malformed responses as seeds
+// The parser must not panic and must return either a result or an error.
+func fuzzXMLParser(f *testing.F, filename string, check func(t *testing.T, result interface{})) {
+	for _, folder := range firmwareCorpus {
+		data, err := ioutil.ReadFile(path.Join(folder, filename))
+		if err == nil {
+			f.Add(data)
+		}
+	}
+	for _, data := range malformedXML {
+		f.Add([]byte(data))
+	}
+	parse := xmlParsers[filename]
+	f.Fuzz(func(t *testing.T, data []byte) {
+		result, err := parse(data)
+		if err != nil {
+			return
+		}
+		require.NotNil(t, result)
+		check(t, result)
+	})
+}
+
+func FuzzParseIsyConfig(f *testing.F) {
+	fuzzXMLParser(f, "config.xml", func(t *testing.T, result interface{}) {
+		isyDevice := result.(*internal.IsyDevice)
+		assert.NotEmpty(t, isyDevice.Configuration.AppVersion)
+		isyDevice.Subsystems()
+	})
+}
+
+func FuzzParseIsyNodes(f *testing.F) {
+	fuzzXMLParser(f, "nodes.xml", func(t *testing.T, result interface{}) {
+		for _, isyNode := range result.(*internal.IsyNodes).Nodes {
+			assert.NotEmpty(t, isyNode.Address)
+			isyNode.InsteonType()
+			isyNode.Group()
+			isyNode.IsSubNode()
+		}
+	})
+}
+
+func FuzzParseIsyStatus(f *testing.F) {
+	fuzzXMLParser(f, "status.xml", func(t *testing.T, result interface{}) {
+		for _, node := range result.(*internal.IsyStatus).Nodes {
+			assert.NotEmpty(t, node.Address)
+			internal.ConvertUOM(node.Prop)
+		}
+	})
+}
+
+func FuzzParseIsyNodeStatus(f *testing.F) {
+	fuzzXMLParser(f, "status/15 2D A 1.xml", func(t *testing.T, result interface{}) {
+		for _, prop := range result.(*internal.IsyNodeStatus).Properties {
+			assert.NotEmpty(t, prop.ID)
+			internal.ConvertUOM(prop)
+		}
+	})
+}
+
+func FuzzParseIsyProp(f *testing.F) {
+	fuzzXMLParser(f, "nodes/2F 11 A3 1/get/OL.xml", func(t *testing.T, result interface{}) {
+		prop := result.(*internal.IsyProp)
+		assert.NotEmpty(t, prop.ID)
+		internal.ConvertUOM(*prop)
+	})
+}
+
+func FuzzParseIsyNodeDefs(f *testing.F) {
+	fuzzXMLParser(f, "nodes/defs.xml", func(t *testing.T, result interface{}) {
+		nodeDefs := result.(*internal.IsyNodeDefs)
+		for i, nodeDef := range nodeDefs.NodeDefs {
+			assert.NotEmpty(t, nodeDef.ID)
+			for _, status := range nodeDef.Statuses {
+				editorRange := nodeDefs.EditorRange(&nodeDefs.NodeDefs[i], status.Editor)
+				if editorRange != nil {
+					assert.LessOrEqual(t, len(editorRange.EnumValues()), 256)
+					_ = editorRange.Validate("1")
+				}
+			}
+		}
+	})
+}
+
+func FuzzParseIsyNodeServers(f *testing.F) {
+	fuzzXMLParser(f, "profiles/ns/0/connection.xml", func(t *testing.T, result interface{}) {
+		for _, nodeServer := range result.(*internal.IsyNodeServers).NodeServers {
+			assert.NotEmpty(t, nodeServer.Profile)
+		}
+	})
+}
+
+func FuzzParseIsyNLS(f *testing.F) {
+	fuzzXMLParser(f, "profiles/family/10/profile/1/download/nls/en_us.txt", func(t *testing.T, result interface{}) {
+		nls := result.(internal.IsyNLS)
+		assert.NotEmpty(t, nls)
+		nls.EnumName(&internal.IsyEditorRange{UOM: "25", Subset: "0-2", NLS: "WF_PT"}, "1")
+	})
+}
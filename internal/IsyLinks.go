@@ -0,0 +1,128 @@
+// Package internal with methods for reading Insteon link tables (ALDB) through the ISY
+package internal
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// REST paths of the device link table as read from the device, and as recorded by the ISY
+const (
+	isyDeviceLinksPath = "/rest/nodes/%s/links/device"
+	isyIsyLinksPath    = "/rest/nodes/%s/links/isy"
+)
+
+// Link record comparison status, see InsteonLincRec in the ISY JSDK
+const (
+	LinkStatusIdentical = "identical" // the link in ISY and device are identical
+	LinkStatusMissing   = "missing"   // the link is in ISY but missing in device
+	LinkStatusExtra     = "extra"     // the link is in device but not in ISY
+	LinkStatusDifferent = "different" // the link at the given address is different between ISY and device
+)
+
+// IsyLinkTable with the link records of a device. Example:
+// <links>
+//    <link addr="4087" flags="E2" group="1" device="13 55 D3" data1="255" data2="28" data3="1"/>
+// </links>
+type IsyLinkTable struct {
+	Records []IsyLinkRecord `xml:"link"`
+}
+
+// IsyLinkRecord with a record of the device link database
+// Flags bit 7 is set when the record is in use. Bit 6 is set for a controller and clear for a responder.
+// For responder links data1 is the on-level and data2 the ramp rate.
+type IsyLinkRecord struct {
+	MemAddress string `xml:"addr,attr"`
+	Flags      string `xml:"flags,attr"`
+	Group      string `xml:"group,attr"`
+	Device     string `xml:"device,attr"`
+	Data1      string `xml:"data1,attr"`
+	Data2      string `xml:"data2,attr"`
+	Data3      string `xml:"data3,attr"`
+}
+
+// linkAddress returns a link memory address as a number, eg 0xFF8 for "0FF8" or "FF8"
+// The ISY and the device don't always report the address with the same number of digits.
+func linkAddress(memAddress string) uint64 {
+	address, _ := strconv.ParseUint(memAddress, 16, 32)
+	return address
+}
+
+// addressKey returns the memory address in the same format for the device and the ISY records
+func (record *IsyLinkRecord) addressKey() string {
+	if _, err := strconv.ParseUint(record.MemAddress, 16, 32); err != nil {
+		return record.MemAddress
+	}
+	return fmt.Sprintf("%04X", linkAddress(record.MemAddress))
+}
+
+// flags returns the record flags as a number
+func (record *IsyLinkRecord) flags() int64 {
+	flags, _ := strconv.ParseInt(record.Flags, 16, 16)
+	return flags
+}
+
+// InUse returns true if the link record is in use
+func (record *IsyLinkRecord) InUse() bool {
+	return record.flags()&0x80 != 0
+}
+
+// IsController returns true if the device is the controller of the link, false for a responder
+func (record *IsyLinkRecord) IsController() bool {
+	return record.flags()&0x40 != 0
+}
+
+// SameLink returns true if both records describe the same link
+func (record *IsyLinkRecord) SameLink(other *IsyLinkRecord) bool {
+	return record.Flags == other.Flags && record.Group == other.Group && record.Device == other.Device &&
+		record.Data1 == other.Data1 && record.Data2 == other.Data2 && record.Data3 == other.Data3
+}
+
+// ReadDeviceLinks reads the link table from the Insteon device through the ISY
+// This sends Insteon messages to the device and can take several seconds.
+func (isyAPI *IsyAPI) ReadDeviceLinks(deviceID string) (*IsyLinkTable, error) {
+	linkTable := IsyLinkTable{}
+	err := isyAPI.isyRequest(fmt.Sprintf(isyDeviceLinksPath, deviceID), &linkTable)
+	return &linkTable, err
+}
+
+// ReadIsyLinks reads the link table of the device as recorded by the ISY
+func (isyAPI *IsyAPI) ReadIsyLinks(deviceID string) (*IsyLinkTable, error) {
+	linkTable := IsyLinkTable{}
+	err := isyAPI.isyRequest(fmt.Sprintf(isyIsyLinksPath, deviceID), &linkTable)
+	return &linkTable, err
+}
+
+// CompareLinks compares the device link table with the ISY record of it
+// This returns the comparison status of each in-use record by memory address. Addresses are
+// formatted as 4 hex digits, eg "0FF8".
+func CompareLinks(deviceLinks *IsyLinkTable, isyLinks *IsyLinkTable) map[string]string {
+	result := make(map[string]string)
+	isyRecords := make(map[string]*IsyLinkRecord)
+	for i := range isyLinks.Records {
+		record := &isyLinks.Records[i]
+		if record.InUse() {
+			isyRecords[record.addressKey()] = record
+		}
+	}
+	for i := range deviceLinks.Records {
+		record := &deviceLinks.Records[i]
+		if !record.InUse() {
+			continue
+		}
+		memAddress := record.addressKey()
+		isyRecord, found := isyRecords[memAddress]
+		if !found {
+			result[memAddress] = LinkStatusExtra
+		} else if record.SameLink(isyRecord) {
+			result[memAddress] = LinkStatusIdentical
+		} else {
+			result[memAddress] = LinkStatusDifferent
+		}
+		delete(isyRecords, memAddress)
+	}
+	for memAddress := range isyRecords {
+		result[memAddress] = LinkStatusMissing
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+// Package internal with methods for reading the ISY error and event logs
+package internal
+
+import (
+	"strings"
+	"time"
+)
+
+// REST paths of the ISY logs
+const (
+	isyErrorLogPath = "/rest/log/error"
+	isyEventLogPath = "/rest/log"
+)
+
+// isyLogTimeFormat is the format of the time of log entries, in the local time of the ISY
+const isyLogTimeFormat = "Mon 2006/01/02 03:04:05 PM"
+
+// isyStartCode is the code of the error log entry of the ISY starting. Example:
+//  Thu 2020/10/08 04:55:00 AM	System	-5	Start
+const isyStartCode = "-5"
+
+// IsyLogEntry with a line of an ISY log
+// The logs are tab separated text, oldest entry first. Example error log line:
+//  Thu 2020/10/08 04:56:54 AM	System	-170001	[UDSockets] RSub:25 error:6
+// Example event log line:
+//  Deck lights	Status	100%	Thu 2020/10/08 04:57:10 AM	System	Log
+type IsyLogEntry struct {
+	Line   string   // the log line as is
+	Fields []string // the tab separated fields
+}
+
+// ParseIsyLog splits the log text into log entries
+// Empty lines are skipped.
+func ParseIsyLog(logText string) []IsyLogEntry {
+	entries := make([]IsyLogEntry, 0)
+	for _, line := range strings.Split(logText, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		entries = append(entries, IsyLogEntry{Line: line, Fields: strings.Split(line, "\t")})
+	}
+	return entries
+}
+
+// NewLogEntries returns the log entries that follow the last seen entry
+// If the last seen entry is no longer in the log, because the log was cleared or rolled over,
+// then all entries are new.
+func NewLogEntries(entries []IsyLogEntry, lastSeen string) []IsyLogEntry {
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Line == lastSeen {
+			return entries[i+1:]
+		}
+	}
+	return entries
+}
+
+// StartTime returns the time the ISY last started according to its error log
+// This returns the zero time if the log has no start entry, eg because the log rolled over.
+// location is the time zone of the ISY clock.
+func StartTime(errorLog []IsyLogEntry, location *time.Location) time.Time {
+	for i := len(errorLog) - 1; i >= 0; i-- {
+		fields := errorLog[i].Fields
+		if len(fields) > 2 && fields[2] == isyStartCode {
+			startTime, err := time.ParseInLocation(isyLogTimeFormat, fields[0], location)
+			if err == nil {
+				return startTime
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// ReadErrorLog reads the ISY error log
+func (isyAPI *IsyAPI) ReadErrorLog() ([]IsyLogEntry, error) {
+	buffer, err := isyAPI.isyRequestRaw(isyErrorLogPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyLog(string(buffer)), nil
+}
+
+// ReadEventLog reads the ISY event log
+func (isyAPI *IsyAPI) ReadEventLog() ([]IsyLogEntry, error) {
+	buffer, err := isyAPI.isyRequestRaw(isyEventLogPath)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyLog(string(buffer)), nil
+}
@@ -0,0 +1,39 @@
+// Package internal with methods for the network resources of the ISY networking module
+package internal
+
+import (
+	"fmt"
+)
+
+// IsyNetResources with the network resources configured on the ISY. Example:
+// <NetConfig>
+//    <NetRule>
+//        <name>Notify sprinkler controller</name>
+//        <id>1</id>
+//        <isModified>false</isModified>
+//        ...
+//    </NetRule>
+// </NetConfig>
+type IsyNetResources struct {
+	Resources []IsyNetResource `xml:"NetRule"`
+}
+
+// IsyNetResource with the identification of a network resource
+type IsyNetResource struct {
+	ID   string `xml:"id"`
+	Name string `xml:"name"`
+}
+
+// ReadNetResources reads the network resources from the networking module
+func (isyAPI *IsyAPI) ReadNetResources() (*IsyNetResources, error) {
+	netResources := IsyNetResources{}
+	err := isyAPI.isyRequest("/rest/networking/resources", &netResources)
+	return &netResources, err
+}
+
+// WriteNetResource triggers a network resource
+// resourceID is the ID of the network resource
+func (isyAPI *IsyAPI) WriteNetResource(resourceID string) error {
+	restPath := fmt.Sprintf("/rest/networking/resources/%s", resourceID)
+	return isyAPI.writeCommand(restPath, "resource-"+resourceID, "triggered")
+}
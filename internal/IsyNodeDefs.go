@@ -0,0 +1,206 @@
+// Package internal with the node definitions of firmware 5.x
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// uomIndex is the unit of measure of enum values
+const uomIndex = "25"
+
+// maxEnumValues limits the number of values of an enum editor subset, eg "0-65535" is not an enum
+const maxEnumValues = 256
+
+// IsyNodeDefs with the definitions of the nodes, firmware 5.x and up. Example:
+// <nodeDefs>
+//    <nodeDef id="ZW_DimmerSwitch" nls="ZWDIM">
+//        <sts>
+//            <st id="ST" editor="ZW_PERCENT"/>
+//        </sts>
+//        <cmds>
+//            <sends/>
+//            <accepts>
+//                <cmd id="DON">
+//                    <p id="" editor="ZW_PERCENT" optional="T"/>
+//                </cmd>
+//                <cmd id="DOF"/>
+//            </accepts>
+//        </cmds>
+//    </nodeDef>
+//    <editors>
+//        <editor id="ZW_PERCENT">
+//            <range uom="51" min="0" max="100" prec="0"/>
+//        </editor>
+//    </editors>
+// </nodeDefs>
+type IsyNodeDefs struct {
+	NodeDefs []IsyNodeDef `xml:"nodeDef"`
+	Editors  []IsyEditor  `xml:"editors>editor"` // editors shared by the node definitions
+}
+
+// IsyNodeDef with the status properties and commands of a kind of node
+// The ID is the nodeDefId of the node.
+type IsyNodeDef struct {
+	ID       string             `xml:"id,attr"`
+	NLS      string             `xml:"nls,attr"` // prefix of the NLS strings of the node definition
+	Statuses []IsyNodeDefStatus `xml:"sts>st"`
+	Accepts  []IsyNodeDefCmd    `xml:"cmds>accepts>cmd"` // commands the node accepts
+	Sends    []IsyNodeDefCmd    `xml:"cmds>sends>cmd"`   // commands the node sends, eg to its links
+	Editors  []IsyEditor        `xml:"editors>editor"`   // editors of this node definition only
+}
+
+// IsyNodeDefStatus with a status property of a node definition and the editor of its values
+type IsyNodeDefStatus struct {
+	ID     string `xml:"id,attr"`
+	Editor string `xml:"editor,attr"`
+	Hide   string `xml:"hide,attr"` // "T" for properties that are not shown
+}
+
+// IsyNodeDefCmd with a command of a node definition and its parameters
+type IsyNodeDefCmd struct {
+	ID     string            `xml:"id,attr"`
+	Params []IsyNodeDefParam `xml:"p"`
+}
+
+// IsyNodeDefParam with a parameter of a command. The unnamed parameter has an empty ID.
+type IsyNodeDefParam struct {
+	ID       string `xml:"id,attr"`
+	Editor   string `xml:"editor,attr"`
+	Init     string `xml:"init,attr"`     // status property with the initial value, eg OL
+	Optional string `xml:"optional,attr"` // "T" for optional parameters
+}
+
+// IsyEditor with the allowed values of a status property or command parameter
+type IsyEditor struct {
+	ID     string           `xml:"id,attr"`
+	Ranges []IsyEditorRange `xml:"range"`
+}
+
+// IsyEditorRange with a range of values in a unit of measure
+// Numeric ranges have a min and max. Enums have a subset of values, eg "0-2,5".
+type IsyEditorRange struct {
+	UOM       string `xml:"uom,attr"`
+	Min       string `xml:"min,attr"`
+	Max       string `xml:"max,attr"`
+	Step      string `xml:"step,attr"`
+	Precision string `xml:"prec,attr"`
+	Subset    string `xml:"subset,attr"`
+	NLS       string `xml:"nls,attr"` // prefix of the NLS names of the enum values
+}
+
+// NodeDef returns the node definition with the given ID, nil if not defined
+func (nodeDefs *IsyNodeDefs) NodeDef(nodeDefID string) *IsyNodeDef {
+	for i := range nodeDefs.NodeDefs {
+		if nodeDefs.NodeDefs[i].ID == nodeDefID {
+			return &nodeDefs.NodeDefs[i]
+		}
+	}
+	return nil
+}
+
+// EditorRange returns the first range of an editor, nil if the editor is not defined
+// Editors of the node definition take precedence over the shared editors.
+func (nodeDefs *IsyNodeDefs) EditorRange(nodeDef *IsyNodeDef, editorID string) *IsyEditorRange {
+	if editorID == "" {
+		return nil
+	}
+	for _, editors := range [][]IsyEditor{nodeDef.Editors, nodeDefs.Editors} {
+		for _, editor := range editors {
+			if editor.ID == editorID && len(editor.Ranges) > 0 {
+				return &editor.Ranges[0]
+			}
+		}
+	}
+	return nil
+}
+
+// Command returns the accepted command with the given ID, nil if the node doesn't accept it
+func (nodeDef *IsyNodeDef) Command(cmdID string) *IsyNodeDefCmd {
+	for i := range nodeDef.Accepts {
+		if nodeDef.Accepts[i].ID == cmdID {
+			return &nodeDef.Accepts[i]
+		}
+	}
+	return nil
+}
+
+// IsEnum returns true if the range is a subset of enum values with the index unit of measure
+// Other units can have a subset too, like 0-1 for a boolean, but their values are not an enum.
+func (editorRange *IsyEditorRange) IsEnum() bool {
+	return editorRange.Subset != "" && editorRange.UOM == uomIndex
+}
+
+// EnumValues returns the values of the subset of an enum range, eg "0-2,5" returns 0, 1, 2 and 5
+// Subsets with more than maxEnumValues values are truncated.
+func (editorRange *IsyEditorRange) EnumValues() []string {
+	values := make([]string, 0)
+	for _, part := range strings.Split(editorRange.Subset, ",") {
+		bounds := strings.SplitN(strings.TrimSpace(part), "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			continue
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				continue
+			}
+		}
+		for value := first; value <= last && len(values) < maxEnumValues; value++ {
+			values = append(values, strconv.Itoa(value))
+		}
+	}
+	return values
+}
+
+// Validate returns an error if the value is not in the range
+func (editorRange *IsyEditorRange) Validate(value string) error {
+	if editorRange.IsEnum() {
+		for _, enumValue := range editorRange.EnumValues() {
+			if enumValue == value {
+				return nil
+			}
+		}
+		return fmt.Errorf("value '%s' is not one of %s", value, editorRange.Subset)
+	}
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("value '%s' is not a number", value)
+	}
+	min, err := strconv.ParseFloat(editorRange.Min, 64)
+	if err == nil && number < min {
+		return fmt.Errorf("value %s is less than %s", value, editorRange.Min)
+	}
+	max, err := strconv.ParseFloat(editorRange.Max, 64)
+	if err == nil && number > max {
+		return fmt.Errorf("value %s is more than %s", value, editorRange.Max)
+	}
+	return nil
+}
+
+// ReadIsyNodeDefs reads the definitions of the nodes, firmware 5.x and up
+func (isyAPI *IsyAPI) ReadIsyNodeDefs() (*IsyNodeDefs, error) {
+	buffer, err := isyAPI.isyRequestRaw("/rest/nodes/defs")
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyNodeDefs(buffer)
+}
+
+// WriteNodeCommand sends a command of the node definition to an isy node
+// deviceID is the ISY node ID
+// command is the ID of the accepted command, eg DON
+// parameter is the value of the unnamed parameter, or empty for commands without parameter
+func (isyAPI *IsyAPI) WriteNodeCommand(deviceID string, command string, parameter string) error {
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/%s", deviceID, command)
+	simKey := ""
+	if parameter != "" {
+		restPath += "/" + parameter
+		// commands with the ID of a property, like OL, set that property
+		simKey = deviceID + "/" + command
+	}
+	return isyAPI.writeCommand(restPath, simKey, parameter)
+}
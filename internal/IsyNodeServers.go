@@ -0,0 +1,106 @@
+// Package internal with the Polyglot node servers of firmware 5.x
+package internal
+
+import (
+	"fmt"
+	"strings"
+)
+
+// IsyNodeServers with the node servers connected to the ISY. Example:
+// <connections>
+//    <connection profile="1" isyusernum="0">
+//        <name>WeatherFlow</name>
+//        <ip>192.168.1.20</ip>
+//        <port>8080</port>
+//    </connection>
+// </connections>
+type IsyNodeServers struct {
+	NodeServers []IsyNodeServer `xml:"connection"`
+}
+
+// IsyNodeServer with a node server and the profile slot of its nodes
+// The nodes of a node server have family FamilyNodeServer with the profile slot as instance.
+type IsyNodeServer struct {
+	Profile string `xml:"profile,attr"` // profile slot
+	Name    string `xml:"name"`
+	IP      string `xml:"ip"`
+	Port    string `xml:"port"`
+}
+
+// IsyNLS with the NLS strings of a node server profile, eg "ST-WF-GV1-NAME" = "Wind Speed"
+// The keys are prefixed with the nls attribute of the node definition or editor range.
+type IsyNLS map[string]string
+
+// NodeName returns the name of the kind of node of a node definition, or "" if not named
+func (nls IsyNLS) NodeName(nodeDef *IsyNodeDef) string {
+	return nls[fmt.Sprintf("ND-%s-NAME", nodeDef.NLS)]
+}
+
+// StatusName returns the name of a status property of a node definition, or "" if not named
+func (nls IsyNLS) StatusName(nodeDef *IsyNodeDef, propertyID string) string {
+	return nls[fmt.Sprintf("ST-%s-%s-NAME", nodeDef.NLS, propertyID)]
+}
+
+// CommandName returns the name of a command of a node definition, or "" if not named
+func (nls IsyNLS) CommandName(nodeDef *IsyNodeDef, cmdID string) string {
+	return nls[fmt.Sprintf("CMD-%s-%s-NAME", nodeDef.NLS, cmdID)]
+}
+
+// EnumName returns the name of an enum value of an editor range, or the value if not named
+func (nls IsyNLS) EnumName(editorRange *IsyEditorRange, value string) string {
+	name, found := nls[fmt.Sprintf("%s-%s", editorRange.NLS, value)]
+	if !found || editorRange.NLS == "" {
+		return value
+	}
+	return name
+}
+
+// enumNames returns the names of the values of an enum range
+func (nls IsyNLS) enumNames(editorRange *IsyEditorRange) []string {
+	names := editorRange.EnumValues()
+	for i, value := range names {
+		names[i] = nls.EnumName(editorRange, value)
+	}
+	return names
+}
+
+// EnumValue returns the enum value with the given name, or the name if it is not a named value
+func (nls IsyNLS) EnumValue(editorRange *IsyEditorRange, name string) string {
+	for _, value := range editorRange.EnumValues() {
+		if strings.EqualFold(nls.EnumName(editorRange, value), name) {
+			return value
+		}
+	}
+	return name
+}
+
+// ReadIsyNodeServers reads the node servers connected to the ISY
+func (isyAPI *IsyAPI) ReadIsyNodeServers() (*IsyNodeServers, error) {
+	buffer, err := isyAPI.isyRequestRaw("/rest/profiles/ns/0/connection")
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyNodeServers(buffer)
+}
+
+// ReadIsyNodeServerDefs reads the node definitions of the node server in a profile slot
+// Node servers define their own nodes, so the same node definition ID can be used by
+// the node servers in other slots.
+func (isyAPI *IsyAPI) ReadIsyNodeServerDefs(profile string) (*IsyNodeDefs, error) {
+	buffer, err := isyAPI.isyRequestRaw(
+		fmt.Sprintf("/rest/profiles/family/%s/profile/%s/download/nodedef/nodedefs.xml", FamilyNodeServer, profile))
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyNodeDefs(buffer)
+}
+
+// ReadIsyNodeServerNLS reads the english NLS strings of the node server in a profile slot
+func (isyAPI *IsyAPI) ReadIsyNodeServerNLS(profile string) (IsyNLS, error) {
+	buffer, err := isyAPI.isyRequestRaw(
+		fmt.Sprintf("/rest/profiles/family/%s/profile/%s/download/nls/en_us.txt", FamilyNodeServer, profile))
+	if err != nil {
+		return nil, err
+	}
+	return ParseIsyNLS(buffer)
+}
@@ -0,0 +1,224 @@
+// Package internal with the validating parsers of the ISY responses
+package internal
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// decodeIsyXML decodes an XML response that must have the given root element
+// Responses with a different root, like an HTML error page or a <RestResponse succeeded="false">,
+// and empty responses return an error instead of an empty result.
+func decodeIsyXML(data []byte, root string, result interface{}) error {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return fmt.Errorf("missing <%s> element", root)
+		} else if err != nil {
+			return err
+		}
+		if start, isStart := token.(xml.StartElement); isStart {
+			if start.Name.Local != root {
+				return fmt.Errorf("expected <%s> element but got <%s>", root, start.Name.Local)
+			}
+			return decoder.DecodeElement(result, &start)
+		}
+	}
+}
+
+// mainProperty returns the main property of a node, which is ST if present, otherwise the first
+// Firmware before 5.x only reports the ST property.
+func mainProperty(props []IsyProp) IsyProp {
+	for _, prop := range props {
+		if prop.ID == "ST" {
+			return prop
+		}
+	}
+	if len(props) == 0 {
+		return IsyProp{}
+	}
+	return props[0]
+}
+
+// ParseIsyConfig parses the /rest/config response with the gateway configuration
+func ParseIsyConfig(data []byte) (*IsyDevice, error) {
+	isyDevice := &IsyDevice{}
+	err := decodeIsyXML(data, "configuration", &isyDevice.Configuration)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyConfig: %s", err)
+	} else if isyDevice.Configuration.AppVersion == "" {
+		return nil, fmt.Errorf("ParseIsyConfig: configuration without app_version")
+	}
+	return isyDevice, nil
+}
+
+// ParseIsyNodes parses the /rest/nodes response with the folders, nodes and scenes
+// Each folder, node and scene must have an address.
+func ParseIsyNodes(data []byte) (*IsyNodes, error) {
+	isyNodes := &IsyNodes{}
+	err := decodeIsyXML(data, "nodes", isyNodes)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyNodes: %s", err)
+	}
+	for _, folder := range isyNodes.Folders {
+		if folder.Address == "" {
+			return nil, fmt.Errorf("ParseIsyNodes: folder '%s' without address", folder.Name)
+		}
+	}
+	for _, isyNode := range isyNodes.Nodes {
+		if isyNode.Address == "" {
+			return nil, fmt.Errorf("ParseIsyNodes: node '%s' without address", isyNode.Name)
+		}
+		isyNode.Property = mainProperty(isyNode.Properties)
+	}
+	for _, group := range isyNodes.Groups {
+		if group.Address == "" {
+			return nil, fmt.Errorf("ParseIsyNodes: scene '%s' without address", group.Name)
+		}
+	}
+	return isyNodes, nil
+}
+
+// ParseIsyStatus parses the /rest/status response with the status of all nodes
+// Each node must have an ID.
+func ParseIsyStatus(data []byte) (*IsyStatus, error) {
+	isyStatus := &IsyStatus{}
+	err := decodeIsyXML(data, "nodes", isyStatus)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyStatus: %s", err)
+	}
+	for i, node := range isyStatus.Nodes {
+		if node.Address == "" {
+			return nil, fmt.Errorf("ParseIsyStatus: node status without id")
+		}
+		isyStatus.Nodes[i].Prop = mainProperty(node.Props)
+	}
+	return isyStatus, nil
+}
+
+// ParseIsyNodeStatus parses the /rest/status/<node> response with the status properties of a node
+// Each property must have an ID.
+func ParseIsyNodeStatus(data []byte) (*IsyNodeStatus, error) {
+	nodeStatus := &IsyNodeStatus{}
+	err := decodeIsyXML(data, "properties", nodeStatus)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyNodeStatus: %s", err)
+	}
+	for _, prop := range nodeStatus.Properties {
+		if prop.ID == "" {
+			return nil, fmt.Errorf("ParseIsyNodeStatus: property without id")
+		}
+	}
+	return nodeStatus, nil
+}
+
+// ParseIsyProp parses a single <property> element with a status property value
+// This also accepts a <properties> element with a single property, as returned by some firmware
+// for the /rest/nodes/<id>/get/<prop> command.
+func ParseIsyProp(data []byte) (*IsyProp, error) {
+	prop := &IsyProp{}
+	err := decodeIsyXML(data, "property", prop)
+	if err != nil && bytes.Contains(data, []byte("<properties")) {
+		nodeStatus, statusErr := ParseIsyNodeStatus(data)
+		if statusErr == nil && len(nodeStatus.Properties) == 1 {
+			return &nodeStatus.Properties[0], nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyProp: %s", err)
+	} else if prop.ID == "" {
+		return nil, fmt.Errorf("ParseIsyProp: property without id")
+	}
+	return prop, nil
+}
+
+// ParseIsyNodeDefs parses the /rest/nodes/defs response with the node definitions
+// Each node definition, status property, command and editor must have an ID.
+func ParseIsyNodeDefs(data []byte) (*IsyNodeDefs, error) {
+	nodeDefs := &IsyNodeDefs{}
+	err := decodeIsyXML(data, "nodeDefs", nodeDefs)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyNodeDefs: %s", err)
+	}
+	err = validateEditors(nodeDefs.Editors)
+	for _, nodeDef := range nodeDefs.NodeDefs {
+		if err != nil {
+			break
+		} else if nodeDef.ID == "" {
+			err = fmt.Errorf("node definition without id")
+			break
+		}
+		for _, status := range nodeDef.Statuses {
+			if status.ID == "" {
+				err = fmt.Errorf("status of node definition '%s' without id", nodeDef.ID)
+			}
+		}
+		for _, cmds := range [][]IsyNodeDefCmd{nodeDef.Accepts, nodeDef.Sends} {
+			for _, cmd := range cmds {
+				if cmd.ID == "" {
+					err = fmt.Errorf("command of node definition '%s' without id", nodeDef.ID)
+				}
+			}
+		}
+		if err == nil {
+			err = validateEditors(nodeDef.Editors)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyNodeDefs: %s", err)
+	}
+	return nodeDefs, nil
+}
+
+// validateEditors returns an error if an editor has no ID
+func validateEditors(editors []IsyEditor) error {
+	for _, editor := range editors {
+		if editor.ID == "" {
+			return fmt.Errorf("editor without id")
+		}
+	}
+	return nil
+}
+
+// ParseIsyNodeServers parses the /rest/profiles/ns/0/connection response with the node servers
+// Each node server must have a profile slot.
+func ParseIsyNodeServers(data []byte) (*IsyNodeServers, error) {
+	nodeServers := &IsyNodeServers{}
+	err := decodeIsyXML(data, "connections", nodeServers)
+	if err != nil {
+		return nil, fmt.Errorf("ParseIsyNodeServers: %s", err)
+	}
+	for _, nodeServer := range nodeServers.NodeServers {
+		if nodeServer.Profile == "" {
+			return nil, fmt.Errorf("ParseIsyNodeServers: node server '%s' without profile", nodeServer.Name)
+		}
+	}
+	return nodeServers, nil
+}
+
+// ParseIsyNLS parses the NLS strings of a node server profile
+// Each line is a 'key = value' pair. Empty lines and comments starting with # are ignored.
+// Other lines, like those of an HTML error page, are an error.
+func ParseIsyNLS(data []byte) (IsyNLS, error) {
+	nls := make(IsyNLS)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" || strings.ContainsAny(key, " <>") {
+			return nil, fmt.Errorf("ParseIsyNLS: line %d is not a 'key = value' pair", i+1)
+		}
+		nls[key] = strings.TrimSpace(parts[1])
+	}
+	if len(nls) == 0 {
+		return nil, fmt.Errorf("ParseIsyNLS: no NLS strings")
+	}
+	return nls, nil
+}
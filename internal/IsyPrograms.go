@@ -0,0 +1,141 @@
+// Package internal with methods for ISY programs and variables
+package internal
+
+import (
+	"fmt"
+)
+
+// Program commands
+const (
+	ProgramCmdRun     = "run"     // run the program conditions
+	ProgramCmdRunThen = "runThen" // run the 'then' actions
+	ProgramCmdRunElse = "runElse" // run the 'else' actions
+	ProgramCmdStop    = "stop"
+	ProgramCmdEnable  = "enable"
+	ProgramCmdDisable = "disable"
+)
+
+// ProgramCommands with the commands that can be sent to a program
+var ProgramCommands = []string{ProgramCmdRun, ProgramCmdRunThen, ProgramCmdRunElse,
+	ProgramCmdStop, ProgramCmdEnable, ProgramCmdDisable}
+
+// IsProgramCommand returns true if the command is one of the program commands
+func IsProgramCommand(command string) bool {
+	for _, programCmd := range ProgramCommands {
+		if command == programCmd {
+			return true
+		}
+	}
+	return false
+}
+
+// Variable types
+const (
+	VarTypeInteger = "1"
+	VarTypeState   = "2"
+)
+
+// IsyPrograms with the programs and program folders. Example:
+// <programs>
+//    <program id="0001" status="true" folder="true">
+//        <name>My Programs</name>
+//    </program>
+//    <program id="0002" parentId="0001" status="false" folder="false" enabled="true" running="idle">
+//        <name>Porch light at sunset</name>
+//        <lastRunTime>2020/10/07 6:45:12 PM</lastRunTime>
+//        <lastFinishTime>2020/10/07 6:45:12 PM</lastFinishTime>
+//    </program>
+// </programs>
+type IsyPrograms struct {
+	Programs []IsyProgram `xml:"program"`
+}
+
+// IsyProgram with the status of a program or program folder
+type IsyProgram struct {
+	ID             string `xml:"id,attr"`
+	ParentID       string `xml:"parentId,attr"`
+	Status         bool   `xml:"status,attr"` // last evaluation of the conditions
+	Folder         bool   `xml:"folder,attr"`
+	Enabled        bool   `xml:"enabled,attr"`
+	Running        string `xml:"running,attr"` // idle, then or else
+	Name           string `xml:"name"`
+	LastRunTime    string `xml:"lastRunTime"`
+	LastFinishTime string `xml:"lastFinishTime"`
+}
+
+// IsyVariables with the values of the variables of a type. Example:
+// <vars>
+//    <var type="1" id="1">
+//        <init>0</init>
+//        <val>3</val>
+//        <ts>20201007 18:45:12</ts>
+//    </var>
+// </vars>
+type IsyVariables struct {
+	Variables []IsyVariable `xml:"var"`
+}
+
+// IsyVariable with the value of a variable
+type IsyVariable struct {
+	Type      string `xml:"type,attr"`
+	ID        string `xml:"id,attr"`
+	Init      string `xml:"init"`
+	Value     string `xml:"val"`
+	Timestamp string `xml:"ts"`
+}
+
+// IsyVariableDefinitions with the names of the variables of a type. Example:
+// <CList type="VAR_INT">
+//    <e id="1" name="guestMode"/>
+// </CList>
+type IsyVariableDefinitions struct {
+	Definitions []struct {
+		ID   string `xml:"id,attr"`
+		Name string `xml:"name,attr"`
+	} `xml:"e"`
+}
+
+// Names returns the variable names by variable ID
+func (definitions *IsyVariableDefinitions) Names() map[string]string {
+	names := make(map[string]string)
+	for _, definition := range definitions.Definitions {
+		names[definition.ID] = definition.Name
+	}
+	return names
+}
+
+// ReadPrograms reads the programs and program folders
+func (isyAPI *IsyAPI) ReadPrograms() (*IsyPrograms, error) {
+	programs := IsyPrograms{}
+	err := isyAPI.isyRequest("/rest/programs?subfolders=true", &programs)
+	return &programs, err
+}
+
+// WriteProgramCommand sends a command to a program
+// programID is the ID of the program, eg 0002
+// command is one of the program commands, eg ProgramCmdRun
+func (isyAPI *IsyAPI) WriteProgramCommand(programID string, command string) error {
+	restPath := fmt.Sprintf("/rest/programs/%s/%s", programID, command)
+	return isyAPI.writeCommand(restPath, "program/"+programID, command)
+}
+
+// ReadVariables reads the values of the variables of the given type
+// varType is VarTypeInteger or VarTypeState
+func (isyAPI *IsyAPI) ReadVariables(varType string) (*IsyVariables, error) {
+	variables := IsyVariables{}
+	err := isyAPI.isyRequest(fmt.Sprintf("/rest/vars/get/%s", varType), &variables)
+	return &variables, err
+}
+
+// ReadVariableDefinitions reads the names of the variables of the given type
+func (isyAPI *IsyAPI) ReadVariableDefinitions(varType string) (*IsyVariableDefinitions, error) {
+	definitions := IsyVariableDefinitions{}
+	err := isyAPI.isyRequest(fmt.Sprintf("/rest/vars/definitions/%s", varType), &definitions)
+	return &definitions, err
+}
+
+// ReadRaw reads the raw response of a REST request, eg to capture simulation files
+// restPath is the REST path, eg /rest/nodes
+func (isyAPI *IsyAPI) ReadRaw(restPath string) ([]byte, error) {
+	return isyAPI.isyRequestRaw(restPath)
+}
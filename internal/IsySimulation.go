@@ -0,0 +1,132 @@
+// Package internal with the faults that can be injected in simulation mode
+package internal
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SimulationFaults with the faults to inject when simulating the gateway with file://
+// Faults make the simulated gateway behave like a gateway with problems, so the error handling
+// of the publisher can be tested deterministically.
+type SimulationFaults struct {
+	// LatencyMs delays each request by this number of milliseconds
+	LatencyMs int `yaml:"latencyMs"`
+	// ErrorRate is the fraction, 0-1, of requests that fail with an HTTP 500 error
+	ErrorRate float64 `yaml:"errorRate"`
+	// Seed of the random errors. Runs with the same seed fail the same requests.
+	Seed int64 `yaml:"seed"`
+	// Unauthorized fails all requests with HTTP 401, as with an invalid login name or password
+	Unauthorized bool `yaml:"unauthorized"`
+	// Offline fails all requests as if the gateway can't be reached
+	Offline bool `yaml:"offline"`
+	// MalformedXML with REST paths, or path prefixes, that return truncated XML
+	MalformedXML []string `yaml:"malformedXml"`
+	// DropCommands accepts commands without applying them, as if the device missed the message
+	DropCommands bool `yaml:"dropCommands"`
+	// Unresponsive with the addresses of devices that don't respond to commands and queries
+	Unresponsive []string `yaml:"unresponsive"`
+}
+
+// simulator injects the configured faults in simulated requests
+type simulator struct {
+	faults SimulationFaults
+	random *rand.Rand
+	mutex  sync.Mutex
+}
+
+// setFaults replaces the faults to inject and restarts the random errors from the seed
+func (sim *simulator) setFaults(faults SimulationFaults) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.faults = faults
+	sim.random = rand.New(rand.NewSource(faults.Seed))
+}
+
+// request applies the latency and returns the simulated error of a request, if any
+func (sim *simulator) request(restPath string) error {
+	sim.mutex.Lock()
+	faults := sim.faults
+	failed := faults.ErrorRate > 0 && sim.random != nil && sim.random.Float64() < faults.ErrorRate
+	sim.mutex.Unlock()
+
+	if faults.LatencyMs > 0 {
+		time.Sleep(time.Duration(faults.LatencyMs) * time.Millisecond)
+	}
+	redactedPath := redactQuery(restPath)
+	if faults.Offline {
+		return fmt.Errorf("isyRequest: Simulated gateway is offline for %s", redactedPath)
+	} else if faults.Unauthorized {
+		return fmt.Errorf("isyRequest: Simulated error code for %s: 401 Unauthorized", redactedPath)
+	} else if failed {
+		return fmt.Errorf("isyRequest: Simulated error code for %s: 500 Internal Server Error", redactedPath)
+	}
+	for _, address := range faults.Unresponsive {
+		if strings.Contains(restPath, "/"+address+"/") || strings.HasSuffix(restPath, "/"+address) {
+			return fmt.Errorf("isyRequest: Simulated device %s is not responding", address)
+		}
+	}
+	return nil
+}
+
+// response returns the response of a request, truncated if the path returns malformed XML
+func (sim *simulator) response(restPath string, response []byte) []byte {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	for _, prefix := range sim.faults.MalformedXML {
+		if strings.HasPrefix(restPath, prefix) {
+			return response[:len(response)/2]
+		}
+	}
+	return response
+}
+
+// dropCommand returns true if commands are accepted without being applied
+func (sim *simulator) dropCommand() bool {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	return sim.faults.DropCommands
+}
+
+// SetSimulationFaults sets the faults to inject in simulation mode
+// This has no effect when accessing a real gateway. Use an empty SimulationFaults to remove all faults.
+func (isyAPI *IsyAPI) SetSimulationFaults(faults SimulationFaults) {
+	isyAPI.simulator.setFaults(faults)
+}
+
+// writeCommand sends a command to the ISY, or in simulation mode remembers the value
+// In simulation mode the command is not sent but the simulated faults apply. A dropped command
+// succeeds without changing the simulated value.
+// simKey is the key of the simulated value, or empty if the command has no value to remember.
+func (isyAPI *IsyAPI) writeCommand(restPath string, simKey string, simValue string) error {
+	if !strings.HasPrefix(isyAPI.address, "file://") {
+		return isyAPI.isyRequest(restPath, nil)
+	}
+	err := isyAPI.simulator.request(restPath)
+	if err != nil || isyAPI.simulator.dropCommand() {
+		return err
+	}
+	if simKey != "" {
+		isyAPI.simMutex.Lock()
+		isyAPI.simulation[simKey] = simValue
+		isyAPI.simMutex.Unlock()
+	}
+	return nil
+}
+
+// simulatedValue returns the last value written in simulation mode to a node or node property
+// The key is the node address, or <address>/<property> for properties other than the main one.
+func (isyAPI *IsyAPI) simulatedValue(simKey string) (value string, found bool) {
+	isyAPI.simMutex.Lock()
+	defer isyAPI.simMutex.Unlock()
+	value, found = isyAPI.simulation[simKey]
+	return value, found
+}
+
+// SetSimulationFaults sets the faults to inject when the gateway of the app is simulated
+func (app *IsyApp) SetSimulationFaults(faults SimulationFaults) {
+	app.isyAPI.SetSimulationFaults(faults)
+}
@@ -0,0 +1,67 @@
+// Package internal with methods for reading the ISY clock
+package internal
+
+import (
+	"strconv"
+	"time"
+)
+
+// ntpEpochOffset is the number of seconds between the NTP epoch, 1900, and the unix epoch, 1970
+const ntpEpochOffset = 2208988800
+
+// IsyTime with the clock and location settings of the ISY. Example:
+// <DT>
+//    <NTP>3811147014</NTP>
+//    <TMZOffset>-25200</TMZOffset>
+//    <DST>true</DST>
+//    <Lat>49.28</Lat>
+//    <Long>-123.12</Long>
+//    <Sunrise>3811155600</Sunrise>
+//    <Sunset>3811196400</Sunset>
+//    <IsMilitary>false</IsMilitary>
+// </DT>
+// Times are in seconds since the NTP epoch.
+type IsyTime struct {
+	NTP       string `xml:"NTP"`
+	TMZOffset string `xml:"TMZOffset"` // time zone offset in seconds
+	DST       bool   `xml:"DST"`
+	Sunrise   string `xml:"Sunrise"`
+	Sunset    string `xml:"Sunset"`
+}
+
+// ntpToTime converts an NTP timestamp to time. This returns the zero time if the timestamp is invalid.
+func ntpToTime(ntpTime string) time.Time {
+	seconds, err := strconv.ParseInt(ntpTime, 10, 64)
+	if err != nil || seconds < ntpEpochOffset {
+		return time.Time{}
+	}
+	return time.Unix(seconds-ntpEpochOffset, 0).UTC()
+}
+
+// Time returns the current time of the ISY clock
+func (isyTime *IsyTime) Time() time.Time {
+	return ntpToTime(isyTime.NTP)
+}
+
+// Location returns the time zone of the ISY clock, including daylight saving time
+func (isyTime *IsyTime) Location() *time.Location {
+	offset, _ := strconv.Atoi(isyTime.TMZOffset)
+	return time.FixedZone("ISY", offset)
+}
+
+// SunriseTime returns the time of sunrise today
+func (isyTime *IsyTime) SunriseTime() time.Time {
+	return ntpToTime(isyTime.Sunrise)
+}
+
+// SunsetTime returns the time of sunset today
+func (isyTime *IsyTime) SunsetTime() time.Time {
+	return ntpToTime(isyTime.Sunset)
+}
+
+// ReadIsyTime reads the ISY clock
+func (isyAPI *IsyAPI) ReadIsyTime() (*IsyTime, error) {
+	isyTime := IsyTime{}
+	err := isyAPI.isyRequest("/rest/time", &isyTime)
+	return &isyTime, err
+}
@@ -0,0 +1,26 @@
+// Package internal with the commands of Z-Wave nodes on an ISY994 with the Z-Wave module
+package internal
+
+import (
+	"fmt"
+)
+
+// Z-Wave door lock commands and status values
+const (
+	zwaveLockCommand    = "SECMD" // secure command with parameter 1 to lock and 0 to unlock
+	zwaveParamLock      = "1"
+	zwaveParamUnlock    = "0"
+	zwaveStatusLocked   = "100"
+	zwaveStatusUnlocked = "0"
+)
+
+// WriteZWaveLock locks or unlocks a Z-Wave door lock
+// deviceID is the ISY node ID
+func (isyAPI *IsyAPI) WriteZWaveLock(deviceID string, locked bool) error {
+	parameter, status := zwaveParamUnlock, zwaveStatusUnlocked
+	if locked {
+		parameter, status = zwaveParamLock, zwaveStatusLocked
+	}
+	restPath := fmt.Sprintf("/rest/nodes/%s/cmd/%s/%s", deviceID, zwaveLockCommand, parameter)
+	return isyAPI.writeCommand(restPath, deviceID, status)
+}
@@ -0,0 +1,73 @@
+// Package internal for KeypadLinc button nodes
+package internal
+
+import (
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// OutputTypeLED with the on/off status of a keypad button LED
+const OutputTypeLED types.OutputType = "led"
+
+// isKeypadLinc returns true if the ISY node is the primary or a button node of a KeypadLinc
+// See the Insteon device category list for the (sub)categories
+func isKeypadLinc(isyNode *IsyNode) bool {
+	category, subCategory := isyNode.InsteonType()
+	switch category {
+	case 1: // dimmable lighting control
+		switch subCategory {
+		case 0x0C, 0x1B, 0x1C, 0x41, 0x42:
+			return true
+		}
+	case 2: // switched lighting control
+		switch subCategory {
+		case 0x05, 0x0F, 0x2C:
+			return true
+		}
+	}
+	return false
+}
+
+// isKeypadButton returns true if the ISY node is a secondary button of a KeypadLinc
+// Button nodes share the 'pnode' of the primary node, which controls the load.
+func isKeypadButton(isyNode *IsyNode) bool {
+	return isKeypadLinc(isyNode) && isyNode.IsSubNode()
+}
+
+// updateKeypadButton adds the button and LED outputs to the primary keypad node and updates the LED status
+func (app *IsyApp) updateKeypadButton(isyNode *IsyNode) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.PrimaryAddress())
+	instance := isyNode.Group()
+
+	if pub.GetNodeByHWID(nodeHWID) == nil {
+		logrus.Warningf("updateKeypadButton: Primary node '%s' of keypad button '%s' not found", nodeHWID, isyNode.Address)
+		return
+	}
+	output := pub.GetOutputByNodeHWID(nodeHWID, types.OutputTypePushButton, instance)
+	if output == nil {
+		pub.CreateOutput(nodeHWID, types.OutputTypePushButton, instance)
+		pub.CreateOutput(nodeHWID, OutputTypeLED, instance)
+	}
+	pub.UpdateOutputValue(nodeHWID, OutputTypeLED, instance, isyOnOffValue(isyNode.Property.Value))
+}
+
+// handleKeypadEvent publishes keypad button presses and LED status changes
+// Button presses are published on the pushbutton output with the command, eg DON, DOF or DFON.
+func (app *IsyApp) handleKeypadEvent(isyNode *IsyNode, event *IsyEvent) {
+	nodeHWID := app.nodeHWID(isyNode.PrimaryAddress())
+	instance := isyNode.Group()
+
+	switch event.Control {
+	case "DON", "DOF", "DFON", "DFOF":
+		logrus.Infof("handleKeypadEvent: Button %s of keypad '%s' pressed: %s", instance, nodeHWID, event.Control)
+		app.pub.UpdateOutputValue(nodeHWID, types.OutputTypePushButton, instance, event.Control)
+	case "ST":
+		isyNode.setStatus(event.property())
+		if isKeypadButton(isyNode) {
+			app.updateKeypadButton(isyNode)
+		} else {
+			app.updateDevice(isyNode)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package internal for reading and publishing the Insteon device link tables
+package internal
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Link table inputs and attributes
+const (
+	// InputTypeReadLinks to read the link table of a device. The value is the device address, or empty for all devices.
+	InputTypeReadLinks types.InputType = "readLinks"
+	// NodeAttrLinkTable with the JSON encoded link table of an Insteon device
+	NodeAttrLinkTable types.NodeAttr = "linkTable"
+	// NodeAttrLinkMismatches with the number of links that differ between the device and the ISY
+	NodeAttrLinkMismatches types.NodeAttr = "linkMismatches"
+)
+
+// LinkInfo with the published information of a link record
+type LinkInfo struct {
+	MemAddress string `json:"memAddress"`
+	Controller bool   `json:"controller"` // false for a responder
+	Group      string `json:"group"`
+	Device     string `json:"device"`
+	OnLevel    int    `json:"onLevel"` // percentage 0-100
+	RampRate   string `json:"rampRate"`
+	Status     string `json:"status"` // comparison with the ISY record, eg identical or missing
+}
+
+// makeLinkInfo returns the published link information of a link record
+func makeLinkInfo(record *IsyLinkRecord, status string) LinkInfo {
+	onLevel, _ := strconv.Atoi(byteToPercent(record.Data1))
+	return LinkInfo{
+		MemAddress: record.MemAddress,
+		Controller: record.IsController(),
+		Group:      record.Group,
+		Device:     record.Device,
+		OnLevel:    onLevel,
+		RampRate:   record.Data2,
+		Status:     status,
+	}
+}
+
+// ReadLinks reads the link table of an Insteon device and compares it with the ISY record
+// The link table is published as a node attribute together with the number of mismatches.
+// Links that are missing from the device are included using the ISY record.
+func (app *IsyApp) ReadLinks(deviceID string) error {
+	deviceLinks, err := app.isyAPI.ReadDeviceLinks(deviceID)
+	if err != nil {
+		logrus.Warningf("ReadLinks: Error reading link table of device %s: %s", deviceID, err)
+		return err
+	}
+	isyLinks, err := app.isyAPI.ReadIsyLinks(deviceID)
+	if err != nil {
+		logrus.Warningf("ReadLinks: Error reading ISY links of device %s: %s", deviceID, err)
+		return err
+	}
+	compared := CompareLinks(deviceLinks, isyLinks)
+	links := make([]LinkInfo, 0)
+	mismatches := 0
+	for _, table := range []*IsyLinkTable{deviceLinks, isyLinks} {
+		for i := range table.Records {
+			record := &table.Records[i]
+			status, found := compared[record.addressKey()]
+			if !record.InUse() || !found {
+				continue
+			}
+			// the device record is used unless the link is missing from the device
+			if table == isyLinks && status != LinkStatusMissing {
+				continue
+			}
+			if status != LinkStatusIdentical {
+				mismatches++
+			}
+			links = append(links, makeLinkInfo(record, status))
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return linkAddress(links[i].MemAddress) > linkAddress(links[j].MemAddress)
+	})
+	linkTable, _ := json.Marshal(links)
+	app.pub.UpdateNodeAttr(app.nodeHWID(deviceID), map[types.NodeAttr]string{
+		NodeAttrLinkTable:      string(linkTable),
+		NodeAttrLinkMismatches: strconv.Itoa(mismatches),
+	})
+	if mismatches > 0 {
+		logrus.Warningf("ReadLinks: Device %s has %d links that differ from the ISY", deviceID, mismatches)
+	}
+	return nil
+}
+
+// ReadAllLinks reads the link tables of all Insteon devices
+// Only primary nodes are read as sub-nodes share the link table of their device. Nodes of other
+// families, like Z-Wave and node servers, don't have an Insteon link table. Battery powered
+// sensors are asleep and don't respond.
+func (app *IsyApp) ReadAllLinks() {
+	app.nodesMutex.Lock()
+	addresses := make([]string, 0, len(app.isyNodes))
+	for address, isyNode := range app.isyNodes {
+		if !isyNode.IsSubNode() && isyNode.FamilyID() == FamilyInsteon && !isInsteonSensor(isyNode) {
+			addresses = append(addresses, address)
+		}
+	}
+	app.nodesMutex.Unlock()
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		_ = app.ReadLinks(address)
+	}
+}
+
+// HandleReadLinks handles the gateway input to read link tables
+func (app *IsyApp) HandleReadLinks(input *types.InputDiscoveryMessage, deviceID string) error {
+	logrus.Infof("IsyApp.HandleReadLinks: Reading link table of '%s'", deviceID)
+	if deviceID == "" {
+		app.ReadAllLinks()
+		return nil
+	}
+	return app.ReadLinks(deviceID)
+}
@@ -0,0 +1,122 @@
+// Package internal for publishing the ISY error and event logs
+package internal
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// Gateway log outputs. Each new log entry is published as a value of the output.
+const (
+	// OutputTypeErrorLog with the entries of the ISY error log
+	OutputTypeErrorLog types.OutputType = "errorLog"
+	// OutputTypeEventLog with the entries of the ISY event log
+	OutputTypeEventLog types.OutputType = "eventLog"
+)
+
+// DefaultLogHistorySize is the default number of recent log entries that are kept for each log
+const DefaultLogHistorySize = 100
+
+// IsyLogs with the last seen and recent entries of the ISY logs
+type IsyLogs struct {
+	historySize int
+	lastSeen    map[types.OutputType]string        // last seen log line by log output
+	recent      map[types.OutputType][]IsyLogEntry // recent log entries by log output, oldest first
+	errorLog    []IsyLogEntry                      // the error log as last read
+	mutex       sync.Mutex
+}
+
+// StartTime returns the time the ISY last started according to the error log as last read
+// This returns the zero time if the start is not in the log.
+func (logs *IsyLogs) StartTime(location *time.Location) time.Time {
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+	return StartTime(logs.errorLog, location)
+}
+
+// Recent returns a copy of the recent entries of the given log, oldest first
+func (logs *IsyLogs) Recent(logType types.OutputType) []IsyLogEntry {
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+	return append([]IsyLogEntry{}, logs.recent[logType]...)
+}
+
+// add returns the entries that were not seen before and adds them to the recent history
+// The first time a log is read all entries are considered seen.
+func (logs *IsyLogs) add(logType types.OutputType, entries []IsyLogEntry) []IsyLogEntry {
+	logs.mutex.Lock()
+	defer logs.mutex.Unlock()
+	if logType == OutputTypeErrorLog {
+		logs.errorLog = entries
+	}
+	if len(entries) == 0 {
+		return entries
+	}
+	lastSeen, found := logs.lastSeen[logType]
+	newEntries := entries
+	if found {
+		newEntries = NewLogEntries(entries, lastSeen)
+	}
+	logs.lastSeen[logType] = entries[len(entries)-1].Line
+	recent := append(logs.recent[logType], newEntries...)
+	if len(recent) > logs.historySize {
+		recent = recent[len(recent)-logs.historySize:]
+	}
+	logs.recent[logType] = recent
+	if !found {
+		return nil
+	}
+	return newEntries
+}
+
+// NewIsyLogs creates the log history keeping the given number of recent entries for each log
+func NewIsyLogs(historySize int) *IsyLogs {
+	if historySize <= 0 {
+		historySize = DefaultLogHistorySize
+	}
+	return &IsyLogs{
+		historySize: historySize,
+		lastSeen:    make(map[types.OutputType]string),
+		recent:      make(map[types.OutputType][]IsyLogEntry),
+	}
+}
+
+// UpdateLogs reads the ISY error and event logs and publishes the new entries on the gateway node
+func (app *IsyApp) UpdateLogs() {
+	errorLog, err := app.isyAPI.ReadErrorLog()
+	if err != nil {
+		logrus.Warningf("UpdateLogs: Error reading the ISY error log: %s", err)
+	} else {
+		app.publishLogEntries(OutputTypeErrorLog, errorLog)
+	}
+	eventLog, err := app.isyAPI.ReadEventLog()
+	if err != nil {
+		logrus.Warningf("UpdateLogs: Error reading the ISY event log: %s", err)
+	} else {
+		app.publishLogEntries(OutputTypeEventLog, eventLog)
+	}
+}
+
+// publishLogEntries publishes the log entries that were not seen before
+func (app *IsyApp) publishLogEntries(logType types.OutputType, entries []IsyLogEntry) {
+	pub := app.pub
+	gwHWID := app.gatewayHWID()
+	if pub.GetOutputByNodeHWID(gwHWID, logType, types.DefaultOutputInstance) == nil {
+		pub.CreateOutput(gwHWID, logType, types.DefaultOutputInstance)
+	}
+	for _, entry := range app.logs.add(logType, entries) {
+		if logType == OutputTypeErrorLog {
+			logrus.Warningf("ISY error log: %s", entry.Line)
+		}
+		pub.UpdateOutputValue(gwHWID, logType, types.DefaultOutputInstance, strings.Join(entry.Fields, ", "))
+	}
+}
+
+// RecentLog returns the recent entries of the ISY error or event log, oldest first
+func (app *IsyApp) RecentLog(logType types.OutputType) []IsyLogEntry {
+	return app.logs.Recent(logType)
+}
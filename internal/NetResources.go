@@ -0,0 +1,58 @@
+// Package internal for the network resources of the ISY
+package internal
+
+import (
+	"strings"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// netResourcePrefix is the hardware ID prefix of network resource nodes
+const netResourcePrefix = "resource-"
+
+// netResourceHWID returns the node hardware ID of a network resource
+func netResourceHWID(resourceID string) string {
+	return netResourcePrefix + resourceID
+}
+
+// isNetResourceNode returns true if the node hardware ID is that of a network resource
+func isNetResourceNode(nodeHWID string) bool {
+	return strings.HasPrefix(nodeHWID, netResourcePrefix)
+}
+
+// UpdateNetResources discovers the network resources and publishes each as a node with a trigger input
+func (app *IsyApp) UpdateNetResources() {
+	pub := app.pub
+	netResources, err := app.isyAPI.ReadNetResources()
+	if err != nil {
+		logrus.Warningf("UpdateNetResources: Error reading network resources: %s", err)
+		return
+	}
+	for _, resource := range netResources.Resources {
+		nodeHWID := app.nodeHWID(netResourceHWID(resource.ID))
+		if pub.GetNodeByHWID(nodeHWID) == nil {
+			pub.CreateNode(nodeHWID, types.NodeTypeButton)
+			pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+				DataType:    types.DataTypeString,
+				Description: "Name of the network resource",
+				Default:     resource.Name,
+			})
+			pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+				types.NodeStatusRunState: types.NodeRunStateReady,
+			})
+			pub.CreateInput(nodeHWID, types.InputTypePushButton, types.DefaultInputInstance, app.HandleInputCommand)
+		}
+	}
+}
+
+// TriggerNetResource triggers the network resource of the input's node
+func (app *IsyApp) TriggerNetResource(input *types.InputDiscoveryMessage) error {
+	resourceID := strings.TrimPrefix(app.isyAddress(input.NodeHWID), netResourcePrefix)
+	logrus.Infof("IsyApp.TriggerNetResource: Address %s. Resource %s", input.Address, resourceID)
+	err := app.isyAPI.WriteNetResource(resourceID)
+	if err != nil {
+		logrus.Errorf("IsyApp.TriggerNetResource: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
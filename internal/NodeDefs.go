@@ -0,0 +1,282 @@
+// Package internal for nodes with outputs and inputs generated from their node definition
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// InputTypeCommand to send a command of the node definition to a node
+// The input instance is the command ID and the value is the command parameter, if any.
+const InputTypeCommand types.InputType = "command"
+
+// Input attributes with the allowed parameter values of a command input
+const (
+	// InputAttrMin with the minimum value of the parameter
+	InputAttrMin types.NodeAttr = "min"
+	// InputAttrMax with the maximum value of the parameter
+	InputAttrMax types.NodeAttr = "max"
+	// InputAttrEnum with the comma separated enum values of the parameter
+	InputAttrEnum types.NodeAttr = "enum"
+)
+
+// nodeDefMainCommands are the accepted commands that are not published as command inputs
+// On and off are the input of the main output and query has its own input.
+var nodeDefMainCommands = map[string]bool{"DON": true, "DOF": true, "QUERY": true}
+
+// readNodeDefs reads the node definitions on firmware 5.x
+// The definitions are read again when a node has a definition ID that was not seen before,
+// as node servers can add definitions at any time.
+func (app *IsyApp) readNodeDefs(isyNodes *IsyNodes) {
+	if !app.isyAPI.Firmware().hasPropertyCommands() {
+		return
+	}
+	nodeDefIDs := make(map[string]bool)
+	changed := false
+	app.nodesMutex.Lock()
+	for _, isyNode := range isyNodes.Nodes {
+		if isyNode.NodeDefID != "" {
+			nodeDefIDs[isyNode.NodeDefID] = true
+			changed = changed || !app.nodeDefIDs[isyNode.NodeDefID]
+		}
+	}
+	app.nodesMutex.Unlock()
+	if !changed {
+		return
+	}
+	nodeDefs, err := app.isyAPI.ReadIsyNodeDefs()
+	if err != nil {
+		logrus.Warningf("readNodeDefs: Error reading node definitions: %s", err)
+		return
+	}
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	app.nodeDefs = nodeDefs
+	app.nodeDefIDs = nodeDefIDs
+}
+
+// getNodeDef returns the node definitions and the definition of the node, nil if not defined
+// Nodes of node servers use the node definitions of their node server.
+func (app *IsyApp) getNodeDef(isyNode *IsyNode) (*IsyNodeDefs, *IsyNodeDef) {
+	if server := app.getNodeServer(isyNode); server != nil {
+		return server.nodeDefs, server.nodeDefs.NodeDef(isyNode.NodeDefID)
+	}
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	if app.nodeDefs == nil || isyNode.NodeDefID == "" {
+		return nil, nil
+	}
+	return app.nodeDefs, app.nodeDefs.NodeDef(isyNode.NodeDefID)
+}
+
+// nodeDefProperty returns the current value of a status property of a node
+// Returns false if the node has not reported the property.
+func (app *IsyApp) nodeDefProperty(isyNode *IsyNode, propertyID string) (prop IsyProp, found bool) {
+	for _, nodeProp := range isyNode.Properties {
+		if nodeProp.ID == propertyID {
+			prop, found = nodeProp, true
+		}
+	}
+	// take the last written value in simulation
+	if strings.HasPrefix(app.gateway.GatewayAddress, "file://") {
+		simKey := isyNode.Address + "/" + propertyID
+		if propertyID == isyNode.Property.ID {
+			simKey = isyNode.Address
+		}
+		if value, simulated := app.isyAPI.simulatedValue(simKey); simulated {
+			prop.ID, prop.Value, found = propertyID, value, true
+		}
+	}
+	return prop, found
+}
+
+// updateNodeDefDevice updates the node with outputs and inputs from its node definition
+// Each status property is published as an output, and each accepted command as a command input
+// with the allowed parameter values. The main status property, ST if defined, has an on/off or
+// dimmer input if the node accepts the on and off commands.
+// Node servers name their properties, commands and enum values with the NLS strings of their profile.
+func (app *IsyApp) updateNodeDefDevice(isyNode *IsyNode, nodeDefs *IsyNodeDefs, nodeDef *IsyNodeDef) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	nls := app.getNodeNLS(isyNode)
+	isSwitch := nodeDef.Command("DON") != nil && nodeDef.Command("DOF") != nil
+	outputTypes := make(map[types.OutputType]bool)
+	for i, status := range nodeDef.Statuses {
+		if status.Hide == "T" {
+			continue
+		}
+		prop, found := app.nodeDefProperty(isyNode, status.ID)
+		editorRange := nodeDefs.EditorRange(nodeDef, status.Editor)
+		if prop.UOM == "" && editorRange != nil {
+			prop.UOM = editorRange.UOM
+		}
+		isMain := status.ID == isyNode.Property.ID || (isyNode.Property.ID == "" && i == 0)
+		label := app.controls.GetControl(status.ID).Label
+		outputType := app.controls.OutputType(status.ID)
+		if name := nls.StatusName(nodeDef, status.ID); name != "" {
+			// custom properties of node servers, like GV1, are known by their name
+			label = name
+			if _, known := knownControlOutputs[status.ID]; !known {
+				outputType = types.OutputType(labelToID(name, status.ID))
+			}
+		}
+		if status.ID == "ST" && isDimmerUOM(prop.UOM) {
+			outputType = types.OutputTypeDimmer
+		}
+		instance := types.DefaultOutputInstance
+		if outputTypes[outputType] {
+			instance = status.ID
+		}
+		outputTypes[outputType] = true
+
+		if isMain && pub.GetNodeByHWID(nodeHWID) == nil {
+			nodeType := app.controls.NodeType(status.ID)
+			if outputType == types.OutputTypeDimmer {
+				nodeType = types.NodeTypeDimmer
+			}
+			app.createNodeDefNode(isyNode, nodeDef, nodeType)
+		}
+		outputValue, unit, knownUOM := ConvertUOM(prop)
+		isEnum := editorRange != nil && editorRange.IsEnum()
+		if isEnum {
+			outputValue = nls.EnumName(editorRange, prop.Value)
+		} else if !knownUOM && status.ID == "ST" {
+			outputValue = isyOnOffValue(outputValue)
+		}
+		if pub.GetOutputByNodeHWID(nodeHWID, outputType, instance) == nil {
+			output := pub.CreateOutput(nodeHWID, outputType, instance)
+			output.Description = label
+			output.Unit = unit
+			if isEnum {
+				output.DataType = types.DataTypeEnum
+				output.EnumValues = nls.enumNames(editorRange)
+			}
+			pub.UpdateOutput(output)
+			if isMain && isSwitch && instance == types.DefaultOutputInstance {
+				pub.CreateInput(nodeHWID, types.InputType(outputType), types.DefaultInputInstance, app.HandleInputCommand)
+			}
+		}
+		if found {
+			pub.UpdateOutputValue(nodeHWID, outputType, instance, outputValue)
+		}
+	}
+	// a node without status properties still needs a node for its commands
+	if pub.GetNodeByHWID(nodeHWID) == nil {
+		app.createNodeDefNode(isyNode, nodeDef, types.NodeTypeUnknown)
+	}
+	for _, cmd := range nodeDef.Accepts {
+		if nodeDefMainCommands[cmd.ID] || pub.GetInputByNodeHWID(nodeHWID, InputTypeCommand, cmd.ID) != nil {
+			continue
+		} else if len(cmd.Params) > 1 {
+			logrus.Debugf("updateNodeDefDevice: Command %s of node %s has more than one parameter", cmd.ID, isyNode.Address)
+			continue
+		}
+		input := pub.CreateInput(nodeHWID, InputTypeCommand, cmd.ID, app.HandleInputCommand)
+		if input == nil {
+			continue
+		}
+		input.Attr = map[types.NodeAttr]string{}
+		if name := nls.CommandName(nodeDef, cmd.ID); name != "" {
+			input.Attr[types.NodeAttrDescription] = name
+		}
+		if len(cmd.Params) == 1 {
+			editorRange := nodeDefs.EditorRange(nodeDef, cmd.Params[0].Editor)
+			if editorRange != nil && editorRange.IsEnum() {
+				input.Attr[InputAttrEnum] = strings.Join(nls.enumNames(editorRange), ",")
+			} else if editorRange != nil {
+				// the range in the unit of the outputs, eg a percentage for a level 0-255
+				input.Attr[InputAttrMin], _, _ = ConvertUOM(IsyProp{Value: editorRange.Min, UOM: editorRange.UOM})
+				input.Attr[InputAttrMax], _, _ = ConvertUOM(IsyProp{Value: editorRange.Max, UOM: editorRange.UOM})
+			}
+		}
+	}
+}
+
+// createNodeDefNode creates the node of a node with a node definition
+// The node definition ID is published as the model. Nodes of node servers are labelled with
+// the name of their node server and the NLS name of their node definition.
+func (app *IsyApp) createNodeDefNode(isyNode *IsyNode, nodeDef *IsyNodeDef, nodeType types.NodeType) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	pub.CreateNode(nodeHWID, nodeType)
+	pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+		DataType:    types.DataTypeString,
+		Description: "Name of ISY node",
+		Default:     isyNode.Name,
+	})
+	attr := map[types.NodeAttr]string{
+		types.NodeAttrModel: nodeDef.ID,
+	}
+	if server := app.getNodeServer(isyNode); server != nil {
+		attr[NodeAttrNodeServer] = server.Name
+		if name := server.nls.NodeName(nodeDef); name != "" {
+			attr[types.NodeAttrDescription] = name
+		}
+	}
+	pub.UpdateNodeAttr(nodeHWID, attr)
+	pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+		types.NodeStatusRunState: types.NodeRunStateReady,
+	})
+}
+
+// acceptsPercentLevel returns true if the node turns on with a level in percent rather than 0-255
+func (app *IsyApp) acceptsPercentLevel(isyNode *IsyNode) bool {
+	nodeDefs, nodeDef := app.getNodeDef(isyNode)
+	if nodeDef == nil {
+		return false
+	}
+	onCmd := nodeDef.Command("DON")
+	if onCmd == nil || len(onCmd.Params) == 0 {
+		return false
+	}
+	editorRange := nodeDefs.EditorRange(nodeDef, onCmd.Params[0].Editor)
+	return editorRange != nil && editorRange.UOM == "51"
+}
+
+// HandleNodeCommand sends the command of a command input to the node
+// The value is the command parameter in the unit of the outputs, which is converted to the unit of
+// the parameter editor and must be in its range. Eg the on level is given as a percentage.
+// Enum parameters of node servers can also be given by their name.
+func (app *IsyApp) HandleNodeCommand(input *types.InputDiscoveryMessage, parameter string) error {
+	address := app.isyAddress(input.NodeHWID)
+	var nodeDefs *IsyNodeDefs
+	var nodeDef *IsyNodeDef
+	var cmd *IsyNodeDefCmd
+	isyNode := app.getIsyNode(address)
+	if isyNode != nil {
+		nodeDefs, nodeDef = app.getNodeDef(isyNode)
+	}
+	if nodeDef != nil {
+		cmd = nodeDef.Command(input.Instance)
+	}
+	if cmd == nil {
+		logrus.Warningf("IsyApp.HandleNodeCommand: Input '%s' is not an accepted command", input.Address)
+		return fmt.Errorf("node %s does not accept command %s", address, input.Instance)
+	}
+	if len(cmd.Params) == 0 {
+		parameter = ""
+	} else if parameter != "" || cmd.Params[0].Optional != "T" {
+		editorRange := nodeDefs.EditorRange(nodeDef, cmd.Params[0].Editor)
+		if editorRange != nil && editorRange.IsEnum() {
+			parameter = app.getNodeNLS(isyNode).EnumValue(editorRange, parameter)
+		} else if editorRange != nil {
+			parameter, _ = ConvertToUOM(parameter, editorRange.UOM)
+		}
+		if editorRange != nil {
+			err := editorRange.Validate(parameter)
+			if err != nil {
+				logrus.Warningf("IsyApp.HandleNodeCommand: Input %s: %s", input.Address, err)
+				return err
+			}
+		}
+	}
+	logrus.Infof("IsyApp.HandleNodeCommand: Address %s. Command %s, Parameter=%s", input.Address, cmd.ID, parameter)
+	err := app.isyAPI.WriteNodeCommand(address, cmd.ID, parameter)
+	if err != nil {
+		logrus.Errorf("IsyApp.HandleNodeCommand: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
@@ -0,0 +1,154 @@
+// Package internal for the nodes of Polyglot node servers
+package internal
+
+import (
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// NodeAttrNodeServer with the name of the node server of a node
+const NodeAttrNodeServer types.NodeAttr = "nodeServer"
+
+// Intervals for reading the node server profiles
+const (
+	// profileCheckInterval is the interval for checking whether the node servers have changed
+	profileCheckInterval = 10 * time.Minute
+	// Profiles that fail to load are retried with a delay that doubles from the min to the max
+	profileRetryMinDelay = time.Minute
+	profileRetryMaxDelay = time.Hour
+)
+
+// loadRetry with the next attempt to load the profile of a slot that failed to load
+type loadRetry struct {
+	next  time.Time
+	delay time.Duration
+}
+
+// nodeServer with the node definitions and NLS strings of the node server in a profile slot
+type nodeServer struct {
+	IsyNodeServer
+	nodeDefs *IsyNodeDefs
+	nls      IsyNLS // nil if the profile has no NLS strings
+}
+
+// isNodeServerNode returns true if the ISY node is a node of a node server
+// The family instance of the node is the profile slot of its node server.
+func isNodeServerNode(isyNode *IsyNode) bool {
+	return isyNode.FamilyID() == FamilyNodeServer
+}
+
+// readNodeServers reads the profiles of the node servers of the nodes on firmware 5.x
+// Profiles are read when their nodes are first seen. The node servers are checked every
+// profileCheckInterval and the profile of a node server whose connection changed, eg after an
+// upgrade, is read again. Profiles that fail to load, or whose slot has no node server, are
+// retried with an increasing delay. Without NLS strings the nodes are published with property IDs
+// as names.
+func (app *IsyApp) readNodeServers(isyNodes *IsyNodes) {
+	if !app.isyAPI.Firmware().hasPropertyCommands() {
+		return
+	}
+	now := time.Now()
+	missing := make(map[string]bool)
+	app.nodesMutex.Lock()
+	for _, isyNode := range isyNodes.Nodes {
+		slot := isyNode.Family.Instance
+		if isNodeServerNode(isyNode) && app.nodeServers[slot] == nil && !now.Before(app.profileRetries[slot].next) {
+			missing[slot] = true
+		}
+	}
+	checkChanges := len(app.nodeServers) > 0 && now.Sub(app.profilesRead) >= profileCheckInterval
+	if len(missing) == 0 && !checkChanges {
+		app.nodesMutex.Unlock()
+		return
+	}
+	app.profilesRead = now
+	app.nodesMutex.Unlock()
+	connections, err := app.isyAPI.ReadIsyNodeServers()
+	if err != nil {
+		logrus.Warningf("readNodeServers: Error reading node servers: %s", err)
+		app.retryProfiles(missing, now)
+		return
+	}
+	for _, connection := range connections.NodeServers {
+		current := app.getProfileNodeServer(connection.Profile)
+		if current != nil && current.IsyNodeServer == connection {
+			continue
+		} else if current == nil && !missing[connection.Profile] {
+			continue
+		}
+		delete(missing, connection.Profile)
+		server, err := app.readNodeServer(connection)
+		if err != nil {
+			logrus.Warningf("readNodeServers: Error reading node definitions of node server %s: %s", connection.Name, err)
+			app.retryProfiles(map[string]bool{connection.Profile: true}, now)
+			continue
+		} else if current != nil {
+			logrus.Infof("readNodeServers: Node server %s in slot %s changed. Profile reloaded", connection.Name, connection.Profile)
+		}
+		app.nodesMutex.Lock()
+		app.nodeServers[connection.Profile] = server
+		delete(app.profileRetries, connection.Profile)
+		app.nodesMutex.Unlock()
+	}
+	// the remaining slots have no node server
+	app.retryProfiles(missing, now)
+}
+
+// readNodeServer reads the node definitions and NLS strings of the profile of a node server
+func (app *IsyApp) readNodeServer(connection IsyNodeServer) (server *nodeServer, err error) {
+	server = &nodeServer{IsyNodeServer: connection}
+	server.nodeDefs, err = app.isyAPI.ReadIsyNodeServerDefs(connection.Profile)
+	if err != nil {
+		return nil, err
+	}
+	server.nls, err = app.isyAPI.ReadIsyNodeServerNLS(connection.Profile)
+	if err != nil {
+		logrus.Warningf("readNodeServers: Node server %s has no NLS strings: %s", connection.Name, err)
+	}
+	return server, nil
+}
+
+// retryProfiles schedules the next attempt to load the profiles of the given slots
+func (app *IsyApp) retryProfiles(slots map[string]bool, now time.Time) {
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	for slot := range slots {
+		retry := app.profileRetries[slot]
+		retry.delay *= 2
+		if retry.delay == 0 {
+			retry.delay = profileRetryMinDelay
+		} else if retry.delay > profileRetryMaxDelay {
+			retry.delay = profileRetryMaxDelay
+		}
+		retry.next = now.Add(retry.delay)
+		app.profileRetries[slot] = retry
+	}
+}
+
+// getProfileNodeServer returns the node server of a profile slot, nil if not read
+func (app *IsyApp) getProfileNodeServer(slot string) *nodeServer {
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	return app.nodeServers[slot]
+}
+
+// getNodeServer returns the node server of a node, nil if not a node server node or not read
+func (app *IsyApp) getNodeServer(isyNode *IsyNode) *nodeServer {
+	if !isNodeServerNode(isyNode) {
+		return nil
+	}
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	return app.nodeServers[isyNode.Family.Instance]
+}
+
+// getNodeNLS returns the NLS strings of the node server of a node, nil if it has none
+func (app *IsyApp) getNodeNLS(isyNode *IsyNode) IsyNLS {
+	server := app.getNodeServer(isyNode)
+	if server == nil {
+		return nil
+	}
+	return server.nls
+}
@@ -3,6 +3,7 @@ package internal
 
 import (
 	"strings"
+	"time"
 
 	"github.com/iotdomain/iotdomain-go/publisher"
 	"github.com/iotdomain/iotdomain-go/types"
@@ -28,37 +29,56 @@ const IsyURL = "http://%s/rest/nodes"
 
 // updateDevice updates the node discovery and output value from the provided isy node
 func (app *IsyApp) updateDevice(isyNode *IsyNode) {
-	nodeHWID := isyNode.Address
+	if isZWaveNode(isyNode) {
+		app.updateZWaveNode(isyNode)
+		return
+	} else if isInsteonSensor(isyNode) {
+		app.updateSensor(isyNode)
+		return
+	} else if isIoLinc(isyNode) {
+		app.updateIoLinc(isyNode)
+		return
+	} else if isEnergyMeter(isyNode) {
+		app.updateEnergyMeter(isyNode, []IsyProp{isyNode.Property})
+		return
+	}
+	// Firmware 5.x node definitions describe the properties and commands of the other nodes.
+	// The devices above keep their own handling, eg to publish sensors as a single node.
+	nodeDefs, nodeDef := app.getNodeDef(isyNode)
+	if nodeDef != nil && !isKeypadLinc(isyNode) {
+		app.updateNodeDefDevice(isyNode, nodeDefs, nodeDef)
+		return
+	}
+	nodeHWID := app.nodeHWID(isyNode.Address)
 	pub := app.pub
-	hasInput := false
-	outputValue := isyNode.Property.Value
+	prop := isyNode.Property
 	// take value from simulation as the given node is a static file
-	if strings.HasPrefix(app.config.GatewayAddress, "file://") {
-		outputValue = app.isyAPI.simulation[isyNode.Address]
+	if strings.HasPrefix(app.gateway.GatewayAddress, "file://") {
+		prop.Value, _ = app.isyAPI.simulatedValue(isyNode.Address)
 	}
 
-	// What node are we dealing with?
-	deviceType := types.NodeTypeUnknown
-	outputType := types.OutputTypeOnOffSwitch
-	switch isyNode.Property.ID {
-	case "ST":
-		deviceType = types.NodeTypeOnOffSwitch
-		outputType = types.OutputTypeOnOffSwitch
-		hasInput = true
-		if outputValue == "" || outputValue == "DOF" || outputValue == "0" || strings.ToLower(outputValue) == "false" {
-			outputValue = "false"
-		} else {
-			outputValue = "true"
+	// What node are we dealing with? The gateway control definitions describe the property.
+	propertyID := prop.ID
+	control := app.controls.GetControl(propertyID)
+	deviceType := app.controls.NodeType(propertyID)
+	outputType := app.controls.OutputType(propertyID)
+	hasInput := app.controls.HasInput(propertyID)
+	// The unit of measure determines the normalized value and unit
+	outputValue, unit, knownUOM := ConvertUOM(prop)
+	if !knownUOM {
+		if propertyID == "ST" {
+			outputValue = isyOnOffValue(outputValue)
+		} else if control.IsNumeric {
+			unit = types.Unit(control.NumericUnit)
 		}
-		break
-	case "OL":
+	}
+	if propertyID == "ST" && isDimmerUOM(prop.UOM) {
 		deviceType = types.NodeTypeDimmer
 		outputType = types.OutputTypeDimmer
-		hasInput = true
-		break
-	case "RR":
-		deviceType = types.NodeTypeUnknown
-		break
+	}
+	isKeypad := isKeypadLinc(isyNode)
+	if isKeypad {
+		deviceType = types.NodeTypeKeypad
 	}
 	// Add new discoveries
 	node := pub.GetNodeByHWID(nodeHWID)
@@ -80,11 +100,18 @@ func (app *IsyApp) updateDevice(isyNode *IsyNode) {
 		// Most ISY nodes have only a single sensor. This is a very basic implementation.
 		// Is it worth adding multi-sensor support?
 		// https://wiki.universal-devices.com/index.php?title=ISY_Developers:API:REST_Interface#Properties
-		pub.CreateOutput(nodeHWID, outputType, types.DefaultOutputInstance)
+		output = pub.CreateOutput(nodeHWID, outputType, types.DefaultOutputInstance)
+		output.Description = control.Label
+		output.Unit = unit
+		pub.UpdateOutput(output)
 		if hasInput {
 			pub.CreateInput(nodeHWID, types.InputType(outputType),
 				types.DefaultInputInstance, app.HandleInputCommand)
 		}
+		// The primary node of a keypad is also its first button
+		if isKeypad {
+			pub.CreateOutput(nodeHWID, types.OutputTypePushButton, isyNode.Group())
+		}
 	}
 
 	//if output.Value() != isyNode.Property.Value {
@@ -94,7 +121,47 @@ func (app *IsyApp) updateDevice(isyNode *IsyNode) {
 	//}
 	// let the adapter decide whether to repeat the same value based on config
 	pub.UpdateOutputValue(nodeHWID, outputType, types.DefaultOutputInstance, outputValue)
+	// optionally also publish the value as formatted by the ISY, eg "On" or "72.5°F"
+	if app.config.PublishFormatted && prop.Formatted != "" {
+		if pub.GetOutputByNodeHWID(nodeHWID, outputType, FormattedOutputInstance) == nil {
+			pub.CreateOutput(nodeHWID, outputType, FormattedOutputInstance)
+		}
+		pub.UpdateOutputValue(nodeHWID, outputType, FormattedOutputInstance, prop.Formatted)
+	}
+	app.updateNodeProperties(isyNode, outputType)
+}
 
+// updateNodeProperties publishes the properties of a node other than its main property
+// Firmware 5.x reports all properties of a node, like the on level and battery level. Older
+// firmware only reports the main property. Properties with the same output type as the main
+// property use the property ID as output instance.
+func (app *IsyApp) updateNodeProperties(isyNode *IsyNode, mainOutputType types.OutputType) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	for _, prop := range isyNode.Properties {
+		if prop.ID == isyNode.Property.ID {
+			continue
+		}
+		// take the last written value in simulation
+		if strings.HasPrefix(app.gateway.GatewayAddress, "file://") {
+			if value, found := app.isyAPI.simulatedValue(isyNode.Address + "/" + prop.ID); found {
+				prop.Value = value
+			}
+		}
+		outputType := app.controls.OutputType(prop.ID)
+		instance := types.DefaultOutputInstance
+		if outputType == mainOutputType {
+			instance = prop.ID
+		}
+		outputValue, unit, _ := ConvertUOM(prop)
+		if pub.GetOutputByNodeHWID(nodeHWID, outputType, instance) == nil {
+			output := pub.CreateOutput(nodeHWID, outputType, instance)
+			output.Description = app.controls.GetControl(prop.ID).Label
+			output.Unit = unit
+			pub.UpdateOutput(output)
+		}
+		pub.UpdateOutputValue(nodeHWID, outputType, instance, outputValue)
+	}
 }
 
 // UpdateDevices discover ISY Nodes from config and ISY gateway
@@ -106,16 +173,66 @@ func (app *IsyApp) UpdateDevices() {
 		logrus.Warningf("DiscoverNodes: Error reading nodes: %s", err)
 		return
 	}
-	// Update new or changed ISY nodes
+	app.setIsyNodes(isyNodes)
+	app.readNodeDefs(isyNodes)
+	app.readNodeServers(isyNodes)
+	// Update new or changed ISY nodes. Sub-nodes are added to their primary node so do them last.
+	for _, isyNode := range isyNodes.Nodes {
+		if !isyNode.IsSubNode() {
+			app.updateDevice(isyNode)
+			app.updateQueryInput(isyNode)
+			if isyNode.ElkID != "" {
+				app.pub.UpdateNodeAttr(app.nodeHWID(isyNode.Address), map[types.NodeAttr]string{NodeAttrElkID: isyNode.ElkID})
+			}
+		}
+	}
 	for _, isyNode := range isyNodes.Nodes {
+		if isyNode.IsSubNode() {
+			app.updateSubNode(isyNode)
+		}
+	}
+	app.UpdateX10Units()
+}
+
+// updateSubNode updates the secondary node of a device with multiple nodes
+// Unknown sub-nodes are published as separate nodes.
+func (app *IsyApp) updateSubNode(isyNode *IsyNode) {
+	if isKeypadButton(isyNode) {
+		app.updateKeypadButton(isyNode)
+	} else {
 		app.updateDevice(isyNode)
 	}
 }
 
+// isyOnOffValue converts an ISY on/off value or command to "true" or "false"
+func isyOnOffValue(value string) string {
+	if value == "" || value == "DOF" || value == "DFOF" || value == "0" || strings.ToLower(value) == "false" {
+		return "false"
+	}
+	return "true"
+}
+
 // Poll polls the ISY gateway for updates to nodes and sensors
 func (app *IsyApp) Poll(pub *publisher.Publisher) {
 	_, err := app.ReadGateway()
 	if err == nil {
 		app.UpdateDevices()
+		app.UpdateEnergyMeters()
+		// the logs have the start time of the gateway, for its uptime
+		app.UpdateLogs()
+		app.UpdateGatewayStatus()
+		// only update the subsystems that are enabled on the gateway
+		subsystems := app.isyDevice.Subsystems()
+		if subsystems.Elk {
+			app.UpdateElk()
+		}
+		if subsystems.Climate {
+			app.UpdateClimate()
+		}
+		if subsystems.Networking {
+			app.UpdateNetResources()
+		}
 	}
+	app.CheckSensorHeartbeats()
+	app.checkQuerySchedule(time.Now())
 }
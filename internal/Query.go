@@ -0,0 +1,93 @@
+// Package internal to query devices to resync the ISY cached status
+package internal
+
+import (
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// InputTypeQuery to query a device for its current status
+// On the gateway node the value is the device address, or empty for all devices.
+const InputTypeQuery types.InputType = "query"
+
+// QueryNode requests the ISY to query a device and publishes the refreshed status
+func (app *IsyApp) QueryNode(deviceID string) error {
+	err := app.isyAPI.Query(deviceID)
+	if err != nil {
+		logrus.Warningf("QueryNode: Error querying device %s: %s", deviceID, err)
+		return err
+	}
+	nodeStatus, err := app.isyAPI.ReadIsyNodeStatus(deviceID)
+	if err != nil {
+		logrus.Warningf("QueryNode: Error reading status of device %s: %s", deviceID, err)
+		return err
+	}
+	isyNode := app.getIsyNode(deviceID)
+	if isyNode == nil {
+		logrus.Warningf("QueryNode: Unknown device %s", deviceID)
+		return nil
+	}
+	isyNode.Properties = nodeStatus.Properties
+	for _, prop := range nodeStatus.Properties {
+		if prop.ID == isyNode.Property.ID {
+			isyNode.Property = prop
+		}
+	}
+	app.updateDevice(isyNode)
+	return nil
+}
+
+// QueryAll requests the ISY to query all devices and publishes the refreshed status
+func (app *IsyApp) QueryAll() error {
+	logrus.Infof("QueryAll: Querying all devices")
+	app.lastQueryAll = time.Now()
+	err := app.isyAPI.Query("")
+	if err != nil {
+		logrus.Warningf("QueryAll: Error querying devices: %s", err)
+		return err
+	}
+	app.UpdateDevices()
+	return nil
+}
+
+// HandleQuery handles the query input of the gateway or of a device
+func (app *IsyApp) HandleQuery(input *types.InputDiscoveryMessage, deviceID string) error {
+	if input.NodeHWID != app.gatewayHWID() {
+		return app.QueryNode(app.isyAddress(input.NodeHWID))
+	} else if deviceID == "" {
+		return app.QueryAll()
+	}
+	return app.QueryNode(deviceID)
+}
+
+// updateQueryInput adds the query input to a device
+// Battery powered sensors are asleep and don't respond to queries.
+func (app *IsyApp) updateQueryInput(isyNode *IsyNode) {
+	if isInsteonSensor(isyNode) {
+		return
+	}
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	if app.pub.GetInputByNodeHWID(nodeHWID, InputTypeQuery, types.DefaultInputInstance) == nil {
+		app.pub.CreateInput(nodeHWID, InputTypeQuery, types.DefaultInputInstance, app.HandleInputCommand)
+	}
+}
+
+// checkQuerySchedule queries all devices once a day at the configured time
+// The query time is configured as hh:mm in local time. No query is scheduled if it is not set.
+func (app *IsyApp) checkQuerySchedule(now time.Time) {
+	if app.config.QueryTime == "" {
+		return
+	}
+	queryTime, err := time.ParseInLocation("15:04", app.config.QueryTime, now.Location())
+	if err != nil {
+		logrus.Warningf("checkQuerySchedule: Invalid query time '%s'", app.config.QueryTime)
+		return
+	}
+	scheduled := time.Date(now.Year(), now.Month(), now.Day(),
+		queryTime.Hour(), queryTime.Minute(), 0, 0, now.Location())
+	if now.After(scheduled) && app.lastQueryAll.Before(scheduled) {
+		_ = app.QueryAll()
+	}
+}
@@ -0,0 +1,172 @@
+// Package internal for battery powered Insteon sensors
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// DefaultSensorTimeoutSec is the time after which a sensor without any report is considered lost
+// Insteon battery powered sensors send a heartbeat about once every 24 hours.
+const DefaultSensorTimeoutSec = 26 * 3600
+
+// OutputTypeWaterLeak with true when a water leak is detected
+const OutputTypeWaterLeak types.OutputType = "waterLeak"
+
+// OutputTypeLowBattery with true when the sensor battery is low
+const OutputTypeLowBattery types.OutputType = "lowBattery"
+
+// OutputTypeDark with true when the dusk/dawn sensor detects it is dark
+// The sensor doesn't report a light level so this is not a luminance output.
+const OutputTypeDark types.OutputType = "dark"
+
+// NodeStatusHeartbeatAge with the number of hours since the sensor last reported
+const NodeStatusHeartbeatAge types.NodeStatus = "heartbeatAge"
+
+// insteonSensor describes the sub-nodes of a battery powered Insteon sensor
+// Sub-nodes that are not listed, like the leak sensor 'dry' and 'heartbeat' nodes, only count
+// as a sign of life.
+type insteonSensor struct {
+	nodeType types.NodeType
+	outputs  map[string]types.OutputType // output type by sub-node group number
+}
+
+// insteonSensors by subcategory of the Insteon security, health and safety category (0x10)
+var insteonSensors = map[int]insteonSensor{
+	// Motion sensor 2842
+	0x01: {types.NodeTypeMultisensor, map[string]types.OutputType{
+		"1": types.OutputTypeMotion, "2": OutputTypeDark, "3": OutputTypeLowBattery}},
+	// TriggerLinc 2421
+	0x02: {types.NodeTypeSensor, map[string]types.OutputType{
+		"1": types.OutputTypeContact}},
+	// Leak sensor 2852
+	0x08: {types.NodeTypeSensor, map[string]types.OutputType{
+		"2": OutputTypeWaterLeak}},
+	// Open/Close sensor 2843
+	0x09: {types.NodeTypeSensor, map[string]types.OutputType{
+		"1": types.OutputTypeContact}},
+	// Hidden door sensor 2845
+	0x0A: {types.NodeTypeSensor, map[string]types.OutputType{
+		"1": types.OutputTypeContact, "3": OutputTypeLowBattery}},
+	// Motion sensor II 2844
+	0x16: {types.NodeTypeMultisensor, map[string]types.OutputType{
+		"1": types.OutputTypeMotion, "2": OutputTypeDark, "3": OutputTypeLowBattery}},
+}
+
+// getInsteonSensor returns the sensor description if the ISY node is a battery powered sensor
+func getInsteonSensor(isyNode *IsyNode) (sensor insteonSensor, isSensor bool) {
+	category, subCategory := isyNode.InsteonType()
+	if category != 0x10 {
+		return sensor, false
+	}
+	sensor, isSensor = insteonSensors[subCategory]
+	return sensor, isSensor
+}
+
+// isInsteonSensor returns true if the ISY node is the primary or a sub-node of a battery powered sensor
+func isInsteonSensor(isyNode *IsyNode) bool {
+	_, isSensor := getInsteonSensor(isyNode)
+	return isSensor
+}
+
+// updateSensor updates the sensor node and the output of the sensor sub-node
+// Sensors only report when they wake up so the ISY can have an empty value. Empty values are not published.
+// Sensors are read-only and have no inputs.
+func (app *IsyApp) updateSensor(isyNode *IsyNode) {
+	pub := app.pub
+	sensor, _ := getInsteonSensor(isyNode)
+	nodeHWID := app.nodeHWID(isyNode.PrimaryAddress())
+	if !isyNode.IsSubNode() && pub.GetNodeByHWID(nodeHWID) == nil {
+		pub.CreateNode(nodeHWID, sensor.nodeType)
+		pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+			DataType:    types.DataTypeString,
+			Description: "Name of ISY node",
+			Default:     isyNode.Name,
+		})
+		pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+			types.NodeStatusRunState: types.NodeRunStateReady,
+		})
+	}
+	if pub.GetNodeByHWID(nodeHWID) == nil {
+		logrus.Warningf("updateSensor: Primary node '%s' of sensor '%s' not found", nodeHWID, isyNode.Address)
+		return
+	}
+	// start the heartbeat clock when this service first sees the sensor, also when the
+	// node was restored from a previous run
+	app.seedSensorLastSeen(nodeHWID, time.Now())
+	outputType, hasOutput := sensor.outputs[isyNode.Group()]
+	if !hasOutput {
+		return
+	}
+	if pub.GetOutputByNodeHWID(nodeHWID, outputType, types.DefaultOutputInstance) == nil {
+		pub.CreateOutput(nodeHWID, outputType, types.DefaultOutputInstance)
+	}
+	if isyNode.Property.Value != "" && isyNode.Property.Value != " " {
+		// the sub-nodes are on when they detect motion, contact, a leak, low battery or darkness
+		outputValue := isyOnOffValue(isyNode.Property.Value)
+		pub.UpdateOutputValue(nodeHWID, outputType, types.DefaultOutputInstance, outputValue)
+	}
+}
+
+// handleSensorEvent records the sensor sign of life and publishes status changes
+func (app *IsyApp) handleSensorEvent(isyNode *IsyNode, event *IsyEvent) {
+	nodeHWID := app.nodeHWID(isyNode.PrimaryAddress())
+	app.setSensorLastSeen(nodeHWID, time.Now())
+	app.pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+		types.NodeStatusRunState: types.NodeRunStateReady,
+		NodeStatusHeartbeatAge:   "0",
+	})
+	if event.Control == "ST" {
+		isyNode.setStatus(event.property())
+		app.updateSensor(isyNode)
+	}
+}
+
+// setSensorLastSeen records the time a sensor last reported
+func (app *IsyApp) setSensorLastSeen(nodeHWID string, lastSeen time.Time) {
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	app.sensorLastSeen[nodeHWID] = lastSeen
+}
+
+// seedSensorLastSeen records the time a sensor was first seen, unless it already has a last seen time
+func (app *IsyApp) seedSensorLastSeen(nodeHWID string, firstSeen time.Time) {
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	if _, found := app.sensorLastSeen[nodeHWID]; !found {
+		app.sensorLastSeen[nodeHWID] = firstSeen
+	}
+}
+
+// CheckSensorHeartbeats updates the heartbeat age of the sensors and flags sensors that stopped reporting
+func (app *IsyApp) CheckSensorHeartbeats() {
+	timeout := time.Duration(app.config.SensorTimeoutSec) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultSensorTimeoutSec * time.Second
+	}
+	app.nodesMutex.Lock()
+	lastSeenCopy := make(map[string]time.Time)
+	for nodeHWID, lastSeen := range app.sensorLastSeen {
+		lastSeenCopy[nodeHWID] = lastSeen
+	}
+	app.nodesMutex.Unlock()
+
+	for nodeHWID, lastSeen := range lastSeenCopy {
+		age := time.Since(lastSeen)
+		status := map[types.NodeStatus]string{
+			NodeStatusHeartbeatAge: fmt.Sprintf("%d", int(age.Hours())),
+		}
+		if age > timeout {
+			prevStatus, _ := app.pub.GetNodeStatus(nodeHWID, types.NodeStatusRunState)
+			if prevStatus != types.NodeRunStateError {
+				logrus.Warningf("CheckSensorHeartbeats: Sensor '%s' has not reported for %s", nodeHWID, age.Round(time.Minute))
+			}
+			status[types.NodeStatusRunState] = types.NodeRunStateError
+			status[types.NodeStatusLastError] = "Sensor stopped reporting. Last seen " + lastSeen.Format(time.RFC3339)
+		}
+		app.pub.UpdateNodeStatus(nodeHWID, status)
+	}
+}
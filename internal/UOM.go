@@ -0,0 +1,155 @@
+// Package internal with the conversion of ISY units of measure
+package internal
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/iotdomain/iotdomain-go/types"
+)
+
+// FormattedOutputInstance is the output instance with the value as formatted by the ISY
+const FormattedOutputInstance = "formatted"
+
+// isyUOM with the unit and value conversion of an ISY unit of measure
+type isyUOM struct {
+	unit    types.Unit
+	convert func(value string) string
+}
+
+// isyUOMs with the known ISY units of measure
+// Older firmware uses names like 'on/off' and '%/on/off'. Firmware 4.x and up uses numeric UOM IDs.
+// See the ISY developer documentation for the full list of UOM IDs.
+var isyUOMs = map[string]isyUOM{
+	"on/off":   {"", isyOnOffValue},
+	"%/on/off": {types.UnitPercent, byteToPercent},
+	"%":        {types.UnitPercent, noConversion},
+	"degrees":  {types.UnitFahrenheit, noConversion},
+	"1":        {types.UnitAmp, noConversion},
+	"2":        {"", isyOnOffValue}, // boolean
+	"4":        {types.UnitCelcius, noConversion},
+	"11":       {"", lockedValue}, // deadbolt status, 0=unlocked, 100=locked
+	"17":       {types.UnitFahrenheit, noConversion},
+	"22":       {types.UnitPercent, noConversion}, // relative humidity
+	"33":       {types.UnitKWH, noConversion},
+	"36":       {types.UnitLux, noConversion},
+	"51":       {types.UnitPercent, noConversion},
+	"57":       {types.UnitSecond, noConversion},
+	"58":       {types.UnitSecond, noConversion},
+	"72":       {types.UnitVolt, noConversion},
+	"73":       {types.UnitWatt, noConversion},
+	"78":       {"", isyOnOffValue}, // 0=off, 100=on
+	"100":      {types.UnitPercent, byteToPercent},
+}
+
+// dimmerUOMs are the units of measure of a status that is a dimmer level rather than on/off
+var dimmerUOMs = map[string]bool{"%/on/off": true, "51": true, "100": true}
+
+// noConversion returns the value as is
+func noConversion(value string) string {
+	return value
+}
+
+// lockedValue converts a deadbolt status to "true" if locked or "false" if unlocked
+// Other states, like 102 for jammed, are returned as is.
+func lockedValue(value string) string {
+	switch value {
+	case "100":
+		return "true"
+	case "0":
+		return "false"
+	}
+	return value
+}
+
+// byteToPercent converts an ISY level 0-255 to a percentage 0-100
+// The DON and DOF commands, used in simulation, convert to 100 and 0.
+func byteToPercent(value string) string {
+	switch value {
+	case "DON", "DFON":
+		return "100"
+	case "", "DOF", "DFOF":
+		return "0"
+	}
+	level, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.Itoa(int(math.Round(level * 100 / 255)))
+}
+
+// parsePercent parses a percentage, eg "50" or "50%", and limits it to 0-100
+func parsePercent(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	return math.Max(0, math.Min(100, percent)), nil
+}
+
+// percentToByte converts a percentage 0-100 to an ISY level 0-255
+func percentToByte(value string) (int, error) {
+	percent, err := parsePercent(value)
+	if err != nil {
+		return 0, err
+	}
+	return int(math.Round(percent * 255 / 100)), nil
+}
+
+// uomInverseConversions with the conversion of normalized values back to the ISY value, for
+// the units of measure whose values are converted
+var uomInverseConversions = map[string]func(value string) (string, error){
+	"%/on/off": percentToByteValue,
+	"100":      percentToByteValue,
+}
+
+// percentToByteValue converts a percentage to an ISY level without limiting it to 0-255
+// Unlike percentToByte, a percentage outside 0-100 gives a level outside the range of the unit.
+func percentToByteValue(value string) (string, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+	if err != nil {
+		return value, err
+	}
+	return strconv.Itoa(int(math.Round(percent * 255 / 100))), nil
+}
+
+// ConvertToUOM converts a normalized value to the raw value in the unit of measure
+// This is the inverse of ConvertUOM, eg a percentage is converted to a level 0-255 for uom 100.
+// Values of units that ConvertUOM doesn't convert are returned as is.
+func ConvertToUOM(value string, uom string) (string, error) {
+	convert, found := uomInverseConversions[uom]
+	if !found {
+		return value, nil
+	}
+	return convert(value)
+}
+
+// applyPrecision applies the decimal precision from newer firmware, eg value 725 with prec 1 is 72.5
+func applyPrecision(value string, precision string) string {
+	prec, err := strconv.Atoi(precision)
+	if err != nil || prec <= 0 {
+		return value
+	}
+	intValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	return strconv.FormatFloat(intValue/math.Pow10(prec), 'f', prec, 64)
+}
+
+// isDimmerUOM returns true if the unit of measure describes a dimmer level
+func isDimmerUOM(uom string) bool {
+	return dimmerUOMs[uom]
+}
+
+// ConvertUOM converts the raw property value to the normalized output value and unit
+// Returns false if the unit of measure is not known, in which case the value is returned as is.
+func ConvertUOM(prop IsyProp) (value string, unit types.Unit, known bool) {
+	value = applyPrecision(prop.Value, prop.Precision)
+	uom, known := isyUOMs[prop.UOM]
+	if !known {
+		return value, "", false
+	}
+	return uom.convert(value), uom.unit, true
+}
@@ -0,0 +1,132 @@
+// Package internal for X10 and A10 devices controlled through the ISY
+package internal
+
+import (
+	"strings"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// X10 command codes as used in the ISY REST API /rest/X10/<address>/<command>
+const (
+	X10CmdAllOff = "1"
+	X10CmdOn     = "3"
+	X10CmdAllOn  = "4"
+	X10CmdBright = "7"
+	X10CmdOff    = "11"
+	X10CmdDim    = "15"
+)
+
+// Input instances of the X10 dim and bright pushbutton inputs
+const (
+	X10InstanceDim    = "dim"
+	X10InstanceBright = "bright"
+)
+
+// x10HWIDPrefix is the prefix of X10 node hardware IDs to avoid collisions with Insteon addresses
+const x10HWIDPrefix = "X10-"
+
+// X10UnitConfig with an X10 unit configuration, loaded from isy99.yaml
+// The ISY cannot discover X10 units so they must be configured.
+type X10UnitConfig struct {
+	Address  string `yaml:"address"`  // house code and unit code, eg A1
+	Name     string `yaml:"name"`     // name of the unit
+	Dimmable bool   `yaml:"dimmable"` // unit supports dim and bright commands
+}
+
+// x10NodeHWID returns the node hardware ID of an X10 unit
+func x10NodeHWID(x10Address string) string {
+	return x10HWIDPrefix + strings.ToUpper(x10Address)
+}
+
+// isX10Node returns true if the node hardware ID is that of an X10 unit
+func isX10Node(nodeHWID string) bool {
+	return strings.HasPrefix(nodeHWID, x10HWIDPrefix)
+}
+
+// x10CommandValue returns the output value resulting from an X10 command
+// Returns "" if the command does not change the unit state.
+func x10CommandValue(x10Cmd string) string {
+	switch x10Cmd {
+	case X10CmdOn, X10CmdAllOn, X10CmdBright, X10CmdDim:
+		return "true"
+	case X10CmdOff, X10CmdAllOff:
+		return "false"
+	}
+	return ""
+}
+
+// UpdateX10Units creates the nodes of the configured X10 units
+// The ISY does not track the state of X10 units. The output is updated from commands that are sent
+// and X10 traffic seen on the event stream.
+func (app *IsyApp) UpdateX10Units() {
+	pub := app.pub
+	for _, x10Unit := range app.gateway.X10Units {
+		nodeHWID := app.nodeHWID(x10NodeHWID(x10Unit.Address))
+		if pub.GetNodeByHWID(nodeHWID) != nil {
+			continue
+		}
+		nodeType := types.NodeTypeOnOffSwitch
+		if x10Unit.Dimmable {
+			nodeType = types.NodeTypeDimmer
+		}
+		pub.CreateNode(nodeHWID, nodeType)
+		pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+			DataType:    types.DataTypeString,
+			Description: "Name of X10 unit",
+			Default:     x10Unit.Name,
+		})
+		pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+			types.NodeStatusRunState: types.NodeRunStateReady,
+		})
+		pub.CreateOutput(nodeHWID, types.OutputTypeSwitch, types.DefaultOutputInstance)
+		pub.CreateInput(nodeHWID, types.InputTypeSwitch, types.DefaultInputInstance, app.HandleInputCommand)
+		if x10Unit.Dimmable {
+			pub.CreateInput(nodeHWID, types.InputTypePushButton, X10InstanceDim, app.HandleInputCommand)
+			pub.CreateInput(nodeHWID, types.InputTypePushButton, X10InstanceBright, app.HandleInputCommand)
+		}
+	}
+}
+
+// SwitchX10 sends the X10 command for the input of an X10 unit and updates its output
+func (app *IsyApp) SwitchX10(input *types.InputDiscoveryMessage, value string) error {
+	x10Address := strings.TrimPrefix(app.isyAddress(input.NodeHWID), x10HWIDPrefix)
+	x10Cmd := X10CmdOn
+	if input.InputType == types.InputTypePushButton {
+		x10Cmd = X10CmdBright
+		if input.Instance == X10InstanceDim {
+			x10Cmd = X10CmdDim
+		}
+	} else if isyOnOffValue(value) == "false" {
+		x10Cmd = X10CmdOff
+	}
+	logrus.Infof("IsyApp.SwitchX10: Address %s. X10 unit %s, command=%s", input.Address, x10Address, x10Cmd)
+	err := app.isyAPI.WriteX10(x10Address, x10Cmd)
+	if err != nil {
+		logrus.Errorf("IsyApp.SwitchX10: Input %s: error writing ISY: %v", input.Address, err)
+		return err
+	}
+	app.pub.UpdateOutputValue(input.NodeHWID, types.OutputTypeSwitch, types.DefaultOutputInstance, x10CommandValue(x10Cmd))
+	return nil
+}
+
+// handleX10Event publishes X10 traffic seen by the ISY
+// The event info contains the X10 address and command code, eg "A1 3". Traffic for units that are
+// not configured is logged and ignored.
+func (app *IsyApp) handleX10Event(event *IsyEvent) {
+	fields := strings.Fields(event.EventInfo.Content)
+	if len(fields) < 2 {
+		logrus.Warningf("IsyApp.handleX10Event: Unexpected X10 event info '%s'", event.EventInfo.Content)
+		return
+	}
+	nodeHWID := app.nodeHWID(x10NodeHWID(fields[0]))
+	outputValue := x10CommandValue(fields[1])
+	if app.pub.GetNodeByHWID(nodeHWID) == nil {
+		logrus.Infof("IsyApp.handleX10Event: X10 traffic for unconfigured unit %s: command %s", fields[0], fields[1])
+		return
+	} else if outputValue == "" {
+		return
+	}
+	app.pub.UpdateOutputValue(nodeHWID, types.OutputTypeSwitch, types.DefaultOutputInstance, outputValue)
+}
@@ -0,0 +1,243 @@
+// Package internal for Z-Wave nodes on an ISY994 with the Z-Wave module
+package internal
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/iotdomain/iotdomain-go/types"
+	"github.com/sirupsen/logrus"
+)
+
+// zwaveDevice with the node type and the main output and input of a kind of Z-Wave device
+type zwaveDevice struct {
+	nodeType   types.NodeType
+	outputType types.OutputType
+	inputType  types.InputType // empty for read-only devices
+}
+
+// Kinds of Z-Wave devices
+var (
+	zwaveLock       = zwaveDevice{types.NodeTypeLock, types.OutputTypeLocked, types.InputTypeLock}
+	zwaveDimmer     = zwaveDevice{types.NodeTypeDimmer, types.OutputTypeDimmer, types.InputTypeDimmer}
+	zwaveSwitch     = zwaveDevice{types.NodeTypeOnOffSwitch, types.OutputTypeSwitch, types.InputTypeSwitch}
+	zwaveMotion     = zwaveDevice{types.NodeTypeSensor, types.OutputTypeMotion, ""}
+	zwaveContact    = zwaveDevice{types.NodeTypeSensor, types.OutputTypeContact, ""}
+	zwaveAlarm      = zwaveDevice{types.NodeTypeAlarm, types.OutputTypeAlarm, ""}
+	zwaveMeter      = zwaveDevice{types.NodeTypePowerMeter, types.OutputTypeElectricPower, ""}
+	zwaveThermostat = zwaveDevice{types.NodeTypeThermostat, types.OutputTypeTemperature, ""}
+	zwaveSensor     = zwaveDevice{types.NodeTypeMultisensor, types.OutputTypeTemperature, ""}
+)
+
+// zwaveGenericClasses with the kind of device identified by the Z-Wave generic device class
+// Binary sensors are refined by their node definition, eg a motion or leak sensor.
+var zwaveGenericClasses = map[int]zwaveDevice{
+	0x08: zwaveThermostat, // thermostat
+	0x10: zwaveSwitch,     // binary switch
+	0x11: zwaveDimmer,     // multilevel switch
+	0x20: zwaveContact,    // binary sensor
+	0x21: zwaveSensor,     // multilevel sensor
+	0x31: zwaveMeter,      // meter
+	0x40: zwaveLock,       // entry control
+}
+
+// zwaveBinarySensorClass is the generic device class of binary sensors
+const zwaveBinarySensorClass = 0x20
+
+// zwaveNodeDefKeywords with keywords of Z-Wave node definition IDs and the kind of device they
+// identify, in order of precedence. Eg "ZW_DoorLock" is a lock rather than a contact sensor.
+// Keywords match the words of the ID, so "ZW_Thermometer" is not a meter.
+var zwaveNodeDefKeywords = []struct {
+	keyword string
+	device  zwaveDevice
+}{
+	{"lock", zwaveLock},
+	{"dimmer", zwaveDimmer},
+	{"thermostat", zwaveThermostat},
+	{"meter", zwaveMeter},
+	{"motion", zwaveMotion},
+	{"door", zwaveContact},
+	{"window", zwaveContact},
+	{"contact", zwaveContact},
+	{"leak", zwaveAlarm},
+	{"flood", zwaveAlarm},
+	{"smoke", zwaveAlarm},
+	{"alarm", zwaveAlarm},
+	{"sensor", zwaveSensor},
+	{"switch", zwaveSwitch},
+	{"relay", zwaveSwitch},
+}
+
+// nodeDefWords returns the lower case words of a node definition ID
+// Words are separated by non-alphanumeric characters and by camel case, eg "ZW_DoorLock" has
+// the words "zw", "door" and "lock".
+func nodeDefWords(nodeDefID string) map[string]bool {
+	words := make(map[string]bool)
+	runes := []rune(nodeDefID)
+	start := 0
+	for i := 0; i <= len(runes); i++ {
+		split := i == len(runes) || !unicode.IsLetter(runes[i]) && !unicode.IsDigit(runes[i])
+		// a new word starts with an upper case letter after a lower case letter, or as the
+		// last upper case letter of an acronym, eg "ZWDimmer"
+		newWord := !split && i > start && unicode.IsUpper(runes[i]) &&
+			(unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1])))
+		if split || newWord {
+			if i > start {
+				words[strings.ToLower(string(runes[start:i]))] = true
+			}
+			start = i
+			if split {
+				start = i + 1
+			}
+		}
+	}
+	return words
+}
+
+// nodeDefDevice returns the kind of device identified by the words of the node definition ID
+// Only the given kinds of device are considered, or all if none are given.
+func nodeDefDevice(nodeDefID string, kinds ...zwaveDevice) (device zwaveDevice, found bool) {
+	words := nodeDefWords(nodeDefID)
+	for _, nodeDef := range zwaveNodeDefKeywords {
+		if !words[nodeDef.keyword] {
+			continue
+		}
+		if len(kinds) == 0 {
+			return nodeDef.device, true
+		}
+		for _, kind := range kinds {
+			if nodeDef.device == kind {
+				return kind, true
+			}
+		}
+	}
+	return device, false
+}
+
+// zwaveStatusUOMs with the kind of device identified by the unit of measure of the status
+// This is used for node definitions that don't identify the device, like the generated
+// "ZY002_153" definitions of firmware 5.x.
+var zwaveStatusUOMs = map[string]zwaveDevice{
+	"11":  zwaveLock,   // deadbolt status
+	"51":  zwaveDimmer, // percent
+	"78":  zwaveSwitch, // 0=off, 100=on
+	"2":   zwaveContact,
+	"73":  zwaveMeter,
+	"4":   zwaveSensor,
+	"17":  zwaveSensor,
+	"100": zwaveDimmer,
+}
+
+// isZWaveNode returns true if the ISY node is a Z-Wave node
+// The eisy reports Z-Wave nodes of its Z-Matter board with their own family.
+func isZWaveNode(isyNode *IsyNode) bool {
+	family := isyNode.FamilyID()
+	return family == FamilyZWave || family == FamilyZMatter
+}
+
+// zwaveDeviceKind returns the kind of device of a Z-Wave node
+// The generic device class identifies the device, otherwise the node definition ID or the unit of
+// the status property. Unknown devices are published as read-only nodes with an output of the
+// main property. A node keeps the kind it was published with, as events don't always report
+// the unit of the status.
+func (app *IsyApp) zwaveDeviceKind(isyNode *IsyNode) zwaveDevice {
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	if device, found := app.zwaveKinds[nodeHWID]; found {
+		return device
+	}
+	device := app.classifyZWaveNode(isyNode)
+	app.zwaveKinds[nodeHWID] = device
+	return device
+}
+
+// classifyZWaveNode determines the kind of device of a Z-Wave node
+func (app *IsyApp) classifyZWaveNode(isyNode *IsyNode) zwaveDevice {
+	genericClass := isyNode.ZWaveGenericClass()
+	if genericClass == zwaveBinarySensorClass {
+		if device, found := nodeDefDevice(isyNode.NodeDefID, zwaveMotion, zwaveAlarm); found {
+			return device
+		}
+	}
+	if device, found := zwaveGenericClasses[genericClass]; found {
+		return device
+	} else if device, found := nodeDefDevice(isyNode.NodeDefID); found {
+		return device
+	} else if device, found := zwaveStatusUOMs[isyNode.Property.UOM]; found {
+		return device
+	}
+	return zwaveDevice{types.NodeTypeUnknown, app.controls.OutputType(isyNode.Property.ID), ""}
+}
+
+// updateZWaveNode updates the node, outputs and input of a Z-Wave device
+// The main property is published as the output of the kind of device. Other properties, like
+// the battery level, are published as additional outputs.
+func (app *IsyApp) updateZWaveNode(isyNode *IsyNode) {
+	pub := app.pub
+	nodeHWID := app.nodeHWID(isyNode.Address)
+	device := app.zwaveDeviceKind(isyNode)
+	prop := isyNode.Property
+	// take value from simulation as the given node is a static file
+	if strings.HasPrefix(app.gateway.GatewayAddress, "file://") {
+		if value, found := app.isyAPI.simulatedValue(isyNode.Address); found {
+			prop.Value = value
+		}
+	}
+	if pub.GetNodeByHWID(nodeHWID) == nil {
+		pub.CreateNode(nodeHWID, device.nodeType)
+		pub.UpdateNodeConfig(nodeHWID, types.NodeAttrName, &types.ConfigAttr{
+			DataType:    types.DataTypeString,
+			Description: "Name of ISY node",
+			Default:     isyNode.Name,
+		})
+		pub.UpdateNodeAttr(nodeHWID, map[types.NodeAttr]string{
+			types.NodeAttrModel: isyNode.NodeDefID,
+		})
+		pub.UpdateNodeStatus(nodeHWID, map[types.NodeStatus]string{
+			types.NodeStatusRunState: types.NodeRunStateReady,
+		})
+	}
+	outputValue, unit, _ := ConvertUOM(prop)
+	if pub.GetOutputByNodeHWID(nodeHWID, device.outputType, types.DefaultOutputInstance) == nil {
+		output := pub.CreateOutput(nodeHWID, device.outputType, types.DefaultOutputInstance)
+		output.Description = app.controls.GetControl(prop.ID).Label
+		output.Unit = unit
+		pub.UpdateOutput(output)
+		if device.inputType != "" {
+			pub.CreateInput(nodeHWID, device.inputType, types.DefaultInputInstance, app.HandleInputCommand)
+		}
+	}
+	pub.UpdateOutputValue(nodeHWID, device.outputType, types.DefaultOutputInstance, outputValue)
+	app.updateNodeProperties(isyNode, device.outputType)
+}
+
+// HandleZWaveInput controls a Z-Wave device with the command of its kind
+// Locks are locked with 'true' and unlocked with 'false'. Dimmers take a percentage
+// that is limited to 0-100.
+func (app *IsyApp) HandleZWaveInput(input *types.InputDiscoveryMessage, value string) error {
+	address := app.isyAddress(input.NodeHWID)
+	var err error
+	logrus.Infof("IsyApp.HandleZWaveInput: Address %s. Input %s, New value=%s", input.Address, input.InputType, value)
+	switch input.InputType {
+	case types.InputTypeLock:
+		lock := strings.ToLower(value)
+		err = app.isyAPI.WriteZWaveLock(address, lock == "true" || lock == "1" || lock == "on" || lock == "locked")
+	case types.InputTypeDimmer:
+		var percent float64
+		percent, err = parsePercent(value)
+		if err == nil {
+			err = app.isyAPI.WriteLevelPercent(address, int(math.Round(percent)))
+		}
+	case types.InputTypeSwitch:
+		err = app.isyAPI.WriteOnOff(address, isyOnOffValue(value) == "true")
+	default:
+		logrus.Warningf("IsyApp.HandleZWaveInput: Input '%s' is not supported for Z-Wave nodes", input.Address)
+		return nil
+	}
+	if err != nil {
+		logrus.Errorf("IsyApp.HandleZWaveInput: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
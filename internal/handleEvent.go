@@ -0,0 +1,66 @@
+// Package internal handles events from the ISY event stream
+package internal
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// HandleIsyEvent for handling events received from the ISY event stream
+// Other events without a node, like the heartbeat, are ignored.
+func (app *IsyApp) HandleIsyEvent(event *IsyEvent) {
+	if event.Control == "_1" && event.Action == "8" {
+		// X10 traffic is reported as a trigger event with the X10 address in the event info
+		app.handleX10Event(event)
+		return
+	} else if event.Control == elkEventControl {
+		app.handleElkEvent(event)
+		return
+	} else if event.Control == systemStatusControl {
+		app.handleSystemStatusEvent(event)
+		return
+	} else if event.Node == "" {
+		return
+	}
+	isyNode := app.getIsyNode(event.Node)
+	if isyNode == nil {
+		logrus.Infof("IsyApp.HandleIsyEvent: Event '%s' for unknown node '%s'. Ignored", event.Control, event.Node)
+		return
+	}
+	if isKeypadLinc(isyNode) {
+		app.handleKeypadEvent(isyNode, event)
+		return
+	} else if isInsteonSensor(isyNode) {
+		app.handleSensorEvent(isyNode, event)
+		return
+	}
+	// Only status changes and known node properties are published as output values
+	if event.Control == "ST" {
+		isyNode.setStatus(event.property())
+		app.updateDevice(isyNode)
+	} else if isyNode.setProperty(event.property()) {
+		// firmware 5.x also reports changes to the other properties of a node
+		app.updateDevice(isyNode)
+	}
+}
+
+// getIsyNode returns a copy of the last read ISY node with the given address, or nil if not known
+func (app *IsyApp) getIsyNode(address string) *IsyNode {
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	isyNode, found := app.isyNodes[address]
+	if !found {
+		return nil
+	}
+	nodeCopy := *isyNode
+	return &nodeCopy
+}
+
+// setIsyNodes holds the last read ISY nodes for use by the event handler
+func (app *IsyApp) setIsyNodes(isyNodes *IsyNodes) {
+	app.nodesMutex.Lock()
+	defer app.nodesMutex.Unlock()
+	app.isyNodes = make(map[string]*IsyNode)
+	for _, isyNode := range isyNodes.Nodes {
+		app.isyNodes[isyNode.Address] = isyNode
+	}
+}
@@ -2,6 +2,7 @@
 package internal
 
 import (
+	"math"
 	"strings"
 	"time"
 
@@ -27,28 +28,101 @@ func (app *IsyApp) SwitchOnOff(input *types.InputDiscoveryMessage, onOffString s
 
 	// input.UpdateValue(onOffString)
 	node := pub.GetNodeByAddress(input.Address)
-	err := app.isyAPI.WriteOnOff(node.HWID, newValue)
+	err := app.isyAPI.WriteOnOff(app.isyAddress(node.HWID), newValue)
 	if err != nil {
 		logrus.Errorf("IsyApp.SwitchOnOff: Input %s: error writing ISY: %v", input.Address, err)
 	}
 	return err
 }
 
+// SetDimmer sets the dimmer level. The value is a percentage, where 0 turns the dimmer off
+func (app *IsyApp) SetDimmer(input *types.InputDiscoveryMessage, percentString string) error {
+	level, err := percentToByte(percentString)
+	if err != nil {
+		logrus.Warningf("IsyApp.SetDimmer: Input %s: invalid level '%s'", input.Address, percentString)
+		return err
+	}
+	logrus.Infof("IsyApp.SetDimmer: Address %s. New level=%d", input.Address, level)
+	isyNode := app.getIsyNode(app.isyAddress(input.NodeHWID))
+	if isyNode != nil && app.acceptsPercentLevel(isyNode) {
+		percent, _ := parsePercent(percentString)
+		err = app.isyAPI.WriteLevelPercent(isyNode.Address, int(math.Round(percent)))
+	} else if level == 0 {
+		err = app.isyAPI.WriteOnOff(app.isyAddress(input.NodeHWID), false)
+	} else {
+		err = app.isyAPI.WriteLevel(app.isyAddress(input.NodeHWID), level)
+	}
+	if err != nil {
+		logrus.Errorf("IsyApp.SetDimmer: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
+
+// SetProperty writes the value of a writable node property
+// The property is the main property of the ISY node, as defined by the gateway controls.
+func (app *IsyApp) SetProperty(input *types.InputDiscoveryMessage, value string) error {
+	isyNode := app.getIsyNode(app.isyAddress(input.NodeHWID))
+	if isyNode == nil || app.controls.GetControl(isyNode.Property.ID).ReadOnly {
+		logrus.Warningf("IsyApp.SetProperty. Input '%s' is not a writable property", input.Address)
+		return nil
+	}
+	logrus.Infof("IsyApp.SetProperty: Address %s. Property %s, New value=%s", input.Address, isyNode.Property.ID, value)
+	err := app.isyAPI.WriteProperty(isyNode.Address, isyNode.Property.ID, value)
+	if err != nil {
+		logrus.Errorf("IsyApp.SetProperty: Input %s: error writing ISY: %v", input.Address, err)
+	}
+	return err
+}
+
 // HandleInputCommand for handling input commands
-// Currently very basic. Only switches are supported.
+// Switches and relays are switched on or off. Other inputs write the node property.
 func (app *IsyApp) HandleInputCommand(
 	input *types.InputDiscoveryMessage, sender string, value string) {
 	logrus.Infof("IsyApp.HandleInputCommand. Input for '%s'", input.Address)
 
 	// payloadStr := string(payload[:])
+	if isX10Node(app.isyAddress(input.NodeHWID)) {
+		_ = app.SwitchX10(input, value)
+		return
+	} else if isNetResourceNode(app.isyAddress(input.NodeHWID)) {
+		_ = app.TriggerNetResource(input)
+		return
+	}
+	// Z-Wave devices are switched, dimmed and locked with their own commands
+	isyNode := app.getIsyNode(app.isyAddress(input.NodeHWID))
+	isZWave := isyNode != nil && isZWaveNode(isyNode)
 
 	// for now only support on/off
 	switch input.InputType {
 	case types.InputTypeSwitch:
 		//adapter.UpdateOutputValue()device.UpdateSensorCommand(sensor, payloadStr)
-		_ = app.SwitchOnOff(input, value)
+		if isZWave {
+			_ = app.HandleZWaveInput(input, value)
+		} else {
+			_ = app.SwitchOnOff(input, value)
+		}
+	case types.InputTypeDimmer:
+		if isZWave {
+			_ = app.HandleZWaveInput(input, value)
+		} else {
+			_ = app.SetDimmer(input, value)
+		}
+	case types.InputTypeLock:
+		_ = app.HandleZWaveInput(input, value)
+	case types.InputTypeRelay:
+		_ = app.SwitchRelay(input, value)
+	case InputTypeArm, InputTypeDisarm:
+		_ = app.ArmElkArea(input, value)
+	case InputTypeResetEnergy:
+		_ = app.ResetEnergy(input)
+	case InputTypeQuery:
+		_ = app.HandleQuery(input, value)
+	case InputTypeReadLinks:
+		_ = app.HandleReadLinks(input, value)
+	case InputTypeCommand:
+		_ = app.HandleNodeCommand(input, value)
 	default:
-		logrus.Warningf("IsyApp.HandleInputCommand. Input '%s' is Not a switch", input.Address)
+		_ = app.SetProperty(input, value)
 	}
 	// publish the result. give gateway time to update.
 	// TODO: get push notification instead
@@ -1,7 +0,0 @@
-package isy99
-
-import "./internal"
-
-func main() {
-	internal.Run()
-}